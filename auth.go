@@ -0,0 +1,223 @@
+package godzilla
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Authentication headers
+const (
+	HeaderAuthorization   = "Authorization"
+	HeaderWWWAuthenticate = "WWW-Authenticate"
+)
+
+// locals keys of authenticated requests
+const (
+	localBasicAuthUser = "godzilla.basicauth.user"
+	localAPIKey        = "godzilla.keyauth.key"
+	localJWTClaims     = "godzilla.jwt.claims"
+)
+
+var (
+	// ErrMissingKey is returned when a request carries no credentials
+	ErrMissingKey = errors.New("missing or malformed credentials")
+
+	// ErrInvalidKey is returned when the validator rejects the credentials
+	ErrInvalidKey = errors.New("invalid credentials")
+)
+
+// BasicAuthConfig holds the basic auth middleware settings
+type BasicAuthConfig struct {
+	// Passwords keyed by user name
+	Users map[string]string // default nil
+
+	// Checks credentials of users missing from Users, e.g. against a database
+	Validator func(user, password string) bool // default nil
+
+	// Realm sent in the WWW-Authenticate header
+	Realm string // default "Restricted"
+
+	// Answers unauthenticated requests
+	Unauthorized func(ctx Context) // default 401 with WWW-Authenticate
+}
+
+// BasicAuth returns a middleware authenticating requests with http basic auth,
+// passwords are compared in constant time and the user is available with
+// BasicAuthUser
+func BasicAuth(config *BasicAuthConfig) handlerFunc {
+	realm := config.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+
+	// hashes have the same length, comparing them does not leak password lengths
+	users := make(map[string][sha256.Size]byte, len(config.Users))
+	for user, password := range config.Users {
+		users[user] = sha256.Sum256([]byte(password))
+	}
+
+	unauthorized := config.Unauthorized
+	if unauthorized == nil {
+		unauthorized = func(ctx Context) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized),
+				fasthttp.StatusUnauthorized)
+			ctx.Set(HeaderWWWAuthenticate, `Basic realm="`+realm+`", charset="UTF-8"`)
+		}
+	}
+
+	return func(ctx Context) {
+		user, password, ok := parseBasicAuth(ctx.Get(HeaderAuthorization))
+		if ok {
+			ok = false
+			if expected, found := users[user]; found {
+				actual := sha256.Sum256([]byte(password))
+				ok = subtle.ConstantTimeCompare(actual[:], expected[:]) == 1
+			} else if config.Validator != nil {
+				ok = config.Validator(user, password)
+			}
+		}
+
+		if !ok {
+			unauthorized(ctx)
+			return
+		}
+
+		ctx.SetLocal(localBasicAuthUser, user)
+		ctx.Next()
+	}
+}
+
+// BasicAuthUser returns the user authenticated by BasicAuth
+func BasicAuthUser(ctx Context) string {
+	user, _ := ctx.GetLocal(localBasicAuthUser).(string)
+	return user
+}
+
+func parseBasicAuth(header string) (user, password string, ok bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	user, password, ok = cut(string(decoded), ":")
+	return user, password, ok
+}
+
+// KeyAuthConfig holds the api key middleware settings
+type KeyAuthConfig struct {
+	// Where the key is read from: "header:<name>", "query:<name>" or "cookie:<name>"
+	Lookup string // default "header:Authorization"
+
+	// Scheme prefix of header values, e.g. "Bearer", empty for raw keys
+	Scheme string // default "Bearer" for the Authorization header
+
+	// Reports whether key is valid, errors are passed to Unauthorized
+	Validator func(ctx Context, key string) (bool, error)
+
+	// Answers unauthenticated requests, err is ErrMissingKey, ErrInvalidKey or
+	// an error of the validator
+	Unauthorized func(ctx Context, err error) // default 401
+}
+
+// KeyAuth returns a middleware authenticating requests with an api key or
+// bearer token checked by a validator, the key is available with APIKey
+func KeyAuth(config *KeyAuthConfig) handlerFunc {
+	if config.Validator == nil {
+		panic("key auth requires a validator")
+	}
+
+	extract := keyExtractor(config.Lookup, config.Scheme)
+
+	unauthorized := config.Unauthorized
+	if unauthorized == nil {
+		unauthorized = func(ctx Context, err error) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized),
+				fasthttp.StatusUnauthorized)
+		}
+	}
+
+	return func(ctx Context) {
+		key := extract(ctx)
+		if key == "" {
+			unauthorized(ctx, ErrMissingKey)
+			return
+		}
+
+		valid, err := config.Validator(ctx, key)
+		if err == nil && !valid {
+			err = ErrInvalidKey
+		}
+		if err != nil {
+			unauthorized(ctx, err)
+			return
+		}
+
+		ctx.SetLocal(localAPIKey, key)
+		ctx.Next()
+	}
+}
+
+// APIKey returns the key authenticated by KeyAuth
+func APIKey(ctx Context) string {
+	key, _ := ctx.GetLocal(localAPIKey).(string)
+	return key
+}
+
+// keyExtractor returns a func reading credentials from where lookup points
+func keyExtractor(lookup, scheme string) func(ctx Context) string {
+	if lookup == "" {
+		lookup = "header:" + HeaderAuthorization
+	}
+
+	source, name, ok := cut(lookup, ":")
+	if !ok || name == "" {
+		panic("invalid key lookup '" + lookup + "'")
+	}
+
+	switch source {
+	case "header":
+		if scheme == "" && strings.EqualFold(name, HeaderAuthorization) {
+			scheme = "Bearer"
+		}
+
+		prefix := scheme + " "
+		return func(ctx Context) string {
+			value := ctx.Get(name)
+			if scheme == "" {
+				return value
+			}
+			if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+				return ""
+			}
+			return strings.TrimSpace(value[len(prefix):])
+		}
+	case "query":
+		return func(ctx Context) string {
+			return ctx.Query(name)
+		}
+	case "cookie":
+		return func(ctx Context) string {
+			return string(ctx.Context().Request.Header.Cookie(name))
+		}
+	}
+
+	panic("invalid key lookup '" + lookup + "'")
+}
+
+// cut slices s around the first instance of sep
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,115 @@
+package godzilla
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// TestBasicAuth tests basic auth with users and a validator
+func TestBasicAuth(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(BasicAuth(&BasicAuthConfig{
+		Users:     map[string]string{"admin": "secret"},
+		Validator: func(user, password string) bool { return user == "guest" && password == "guest" },
+	}))
+	gz.Get("/user", func(ctx Context) {
+		ctx.SendString(BasicAuthUser(ctx))
+	})
+	startGodzilla(gz)
+
+	testCases := []struct {
+		user       string
+		password   string
+		statusCode int
+		body       string
+	}{
+		{user: "admin", password: "secret", statusCode: StatusOK, body: "admin"},
+		{user: "guest", password: "guest", statusCode: StatusOK, body: "guest"},
+		{user: "admin", password: "guess", statusCode: StatusUnauthorized},
+		{statusCode: StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, "/user", nil)
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.password)
+		}
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.user, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s: returned %d expected %d", tc.user, response.StatusCode, tc.statusCode)
+		}
+
+		if tc.statusCode == StatusUnauthorized && response.Header.Get(HeaderWWWAuthenticate) == "" {
+			t.Fatalf("%s: returned no %s header", tc.user, HeaderWWWAuthenticate)
+		}
+	}
+}
+
+// TestKeyAuth tests api keys read from headers, queries and cookies
+func TestKeyAuth(t *testing.T) {
+	errRevoked := errors.New("revoked")
+	validator := func(ctx Context, key string) (bool, error) {
+		if key == "revoked" {
+			return false, errRevoked
+		}
+		return key == "valid", nil
+	}
+
+	testCases := []struct {
+		lookup     string
+		header     string
+		value      string
+		statusCode int
+		err        error
+	}{
+		{lookup: "", header: HeaderAuthorization, value: "Bearer valid", statusCode: StatusOK},
+		{lookup: "", header: HeaderAuthorization, value: "valid", statusCode: StatusUnauthorized, err: ErrMissingKey},
+		{lookup: "header:X-API-Key", header: "X-API-Key", value: "valid", statusCode: StatusOK},
+		{lookup: "header:X-API-Key", header: "X-API-Key", value: "invalid", statusCode: StatusUnauthorized, err: ErrInvalidKey},
+		{lookup: "header:X-API-Key", header: "X-API-Key", value: "revoked", statusCode: StatusUnauthorized, err: errRevoked},
+		{lookup: "query:api_key", statusCode: StatusOK},
+		{lookup: "cookie:token", header: "Cookie", value: "token=valid", statusCode: StatusOK},
+	}
+
+	for _, tc := range testCases {
+		var reported error
+
+		gz := setupGodzilla()
+		gz.Use(KeyAuth(&KeyAuthConfig{
+			Lookup:    tc.lookup,
+			Validator: validator,
+			Unauthorized: func(ctx Context, err error) {
+				reported = err
+				ctx.Status(StatusUnauthorized)
+			},
+		}))
+		gz.Get("/key", func(ctx Context) {
+			ctx.SendString(APIKey(ctx))
+		})
+		startGodzilla(gz)
+
+		req, _ := http.NewRequest(MethodGet, "/key?api_key=valid", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.lookup, tc.value, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s(%s): returned %d expected %d", tc.lookup, tc.value, response.StatusCode, tc.statusCode)
+		}
+
+		if reported != tc.err {
+			t.Fatalf("%s(%s): reported %v expected %v", tc.lookup, tc.value, reported, tc.err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package godzilla
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// BreakerState is the state of a circuit
+type BreakerState int
+
+// Circuit states
+const (
+	StateClosed   BreakerState = iota // requests pass, failures are counted
+	StateOpen                         // requests are rejected until OpenTimeout passed
+	StateHalfOpen                     // a few probe requests decide whether to close again
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig holds the circuit breaker settings
+type BreakerConfig struct {
+	// Requests counted in a window before the failure ratio can open the circuit
+	MinRequests int // default 20
+
+	// Ratio of failed requests in a window opening the circuit
+	FailureRatio float64 // default 0.5
+
+	// Requests slower than this count as failures
+	SlowThreshold time.Duration // default 0 (latency is not checked)
+
+	// Duration after which the counts of a closed circuit are reset
+	Window time.Duration // default 10 seconds
+
+	// Duration a circuit stays open before probe requests are let through
+	OpenTimeout time.Duration // default 30 seconds
+
+	// Consecutive successful probes closing a half-open circuit, a failed
+	// probe opens it again
+	HalfOpenRequests int // default 1
+
+	// Reports whether a request failed
+	IsFailure func(ctx Context) bool // default status >= 500
+
+	// Returns the circuit of a request
+	Key func(ctx Context) string // default KeyByRoute()
+
+	// Called when a circuit changes its state
+	OnStateChange func(key string, from, to BreakerState) // default nil
+
+	// Answers requests rejected by an open circuit
+	Open func(ctx Context) // default 503 Service Unavailable
+}
+
+// circuit counts the outcome of the requests of one key
+type circuit struct {
+	mutex    sync.Mutex
+	state    BreakerState
+	since    time.Time // start of the window or of the open state
+	requests int
+	failures int
+	probes   int // probe requests in flight while half-open
+	passed   int // successful probes while half-open
+}
+
+// Breaker returns a middleware rejecting requests with 503 and Retry-After
+// while the circuit of their route is open, it opens once the failure ratio
+// of a window is reached
+func Breaker(config ...*BreakerConfig) handlerFunc {
+	cfg := &BreakerConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	minRequests := cfg.MinRequests
+	if minRequests <= 0 {
+		minRequests = 20
+	}
+
+	failureRatio := cfg.FailureRatio
+	if failureRatio <= 0 {
+		failureRatio = 0.5
+	}
+
+	window := durationOr(cfg.Window, 10*time.Second)
+	openTimeout := durationOr(cfg.OpenTimeout, 30*time.Second)
+
+	halfOpenRequests := cfg.HalfOpenRequests
+	if halfOpenRequests <= 0 {
+		halfOpenRequests = 1
+	}
+
+	isFailure := cfg.IsFailure
+	if isFailure == nil {
+		isFailure = func(ctx Context) bool {
+			return ctx.Context().Response.StatusCode() >= StatusInternalServerError
+		}
+	}
+
+	key := cfg.Key
+	if key == nil {
+		key = KeyByRoute()
+	}
+
+	open := cfg.Open
+	if open == nil {
+		open = func(ctx Context) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusServiceUnavailable),
+				fasthttp.StatusServiceUnavailable)
+		}
+	}
+
+	var mutex sync.Mutex
+	circuits := make(map[string]*circuit)
+
+	transition := func(k string, c *circuit, to BreakerState, now time.Time) {
+		from := c.state
+		c.state, c.since = to, now
+		c.requests, c.failures, c.probes, c.passed = 0, 0, 0, 0
+		if cfg.OnStateChange != nil && from != to {
+			cfg.OnStateChange(k, from, to)
+		}
+	}
+
+	// allow reports whether a request may pass, probe is set for the probe
+	// requests of a half-open circuit
+	allow := func(k string, c *circuit) (ok, probe bool, retryAfter time.Duration) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		now := time.Now()
+		switch c.state {
+		case StateOpen:
+			if wait := openTimeout - now.Sub(c.since); wait > 0 {
+				return false, false, wait
+			}
+			transition(k, c, StateHalfOpen, now)
+			fallthrough
+		case StateHalfOpen:
+			if c.probes+c.passed >= halfOpenRequests {
+				return false, false, time.Second
+			}
+			c.probes++
+			return true, true, 0
+		default:
+			if now.Sub(c.since) >= window {
+				c.since, c.requests, c.failures = now, 0, 0
+			}
+			return true, false, 0
+		}
+	}
+
+	done := func(k string, c *circuit, probe, failed bool) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		now := time.Now()
+		if probe {
+			// the circuit may have been opened by another probe
+			if c.state != StateHalfOpen {
+				return
+			}
+			c.probes--
+			if failed {
+				transition(k, c, StateOpen, now)
+			} else if c.passed++; c.passed >= halfOpenRequests {
+				transition(k, c, StateClosed, now)
+			}
+			return
+		}
+
+		if c.state != StateClosed {
+			return
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= minRequests && float64(c.failures)/float64(c.requests) >= failureRatio {
+			transition(k, c, StateOpen, now)
+		}
+	}
+
+	return func(ctx Context) {
+		k := key(ctx)
+
+		mutex.Lock()
+		c, ok := circuits[k]
+		if !ok {
+			c = &circuit{since: time.Now()}
+
+			// k may alias a request buffer, see GetString
+			circuits[string([]byte(k))] = c
+		}
+		mutex.Unlock()
+
+		allowed, probe, retryAfter := allow(k, c)
+		if !allowed {
+			open(ctx)
+
+			// set after the handler, fasthttp errors reset the response headers
+			ctx.Set(HeaderRetryAfter, strconv.Itoa(ceilSeconds(retryAfter)))
+			return
+		}
+
+		start := time.Now()
+		failed := true
+		defer func() {
+			// panics count as failures and keep unwinding
+			done(k, c, probe, failed)
+		}()
+
+		ctx.Next()
+		failed = isFailure(ctx) || (cfg.SlowThreshold > 0 && time.Since(start) > cfg.SlowThreshold)
+	}
+}
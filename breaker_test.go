@@ -0,0 +1,102 @@
+package godzilla
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestBreaker tests opening on failures and latency, and closing after probes
+func TestBreaker(t *testing.T) {
+	var failing, slow int32 = 1, 0
+	var calls int32
+	var changes []string
+
+	gz := setupGodzilla()
+	gz.Use(Breaker(&BreakerConfig{
+		MinRequests:   4,
+		SlowThreshold: 20 * time.Millisecond,
+		OpenTimeout:   50 * time.Millisecond,
+		OnStateChange: func(key string, from, to BreakerState) {
+			changes = append(changes, key+" "+from.String()+" "+to.String())
+		},
+	}))
+	gz.Get("/dependency", func(ctx Context) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&slow) == 1 {
+			time.Sleep(30 * time.Millisecond)
+		}
+		if atomic.LoadInt32(&failing) == 1 {
+			ctx.Status(StatusInternalServerError)
+		}
+	})
+	gz.Get("/other", pingHandler)
+	startGodzilla(gz)
+
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest(MethodGet, path, nil)
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	for i := 0; i < 4; i++ {
+		if response := get("/dependency"); response.StatusCode != StatusInternalServerError {
+			t.Fatalf("returned %d before opening", response.StatusCode)
+		}
+	}
+
+	// the circuit is open, other routes are not affected
+	response := get("/dependency")
+	if response.StatusCode != StatusServiceUnavailable || response.Header.Get(HeaderRetryAfter) != "1" {
+		t.Fatalf("open circuit returned %d with Retry-After %q", response.StatusCode, response.Header.Get(HeaderRetryAfter))
+	}
+	if atomic.LoadInt32(&calls) != 4 {
+		t.Fatalf("open circuit called the handler")
+	}
+	if response := get("/other"); response.StatusCode != StatusOK {
+		t.Fatalf("other route returned %d", response.StatusCode)
+	}
+
+	// a failed probe opens the circuit again, a successful one closes it
+	time.Sleep(60 * time.Millisecond)
+	get("/dependency")
+	if response := get("/dependency"); response.StatusCode != StatusServiceUnavailable {
+		t.Fatalf("reopened circuit returned %d", response.StatusCode)
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(60 * time.Millisecond)
+	if response := get("/dependency"); response.StatusCode != StatusOK {
+		t.Fatalf("probe returned %d", response.StatusCode)
+	}
+
+	// slow requests count as failures
+	atomic.StoreInt32(&slow, 1)
+	for i := 0; i < 4; i++ {
+		get("/dependency")
+	}
+	if response := get("/dependency"); response.StatusCode != StatusServiceUnavailable {
+		t.Fatalf("slow dependency returned %d", response.StatusCode)
+	}
+
+	expected := []string{
+		"/dependency closed open",
+		"/dependency open half-open",
+		"/dependency half-open open",
+		"/dependency open half-open",
+		"/dependency half-open closed",
+		"/dependency closed open",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("changed states %v expected %v", changes, expected)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Fatalf("changed states %v expected %v", changes, expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package godzilla
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// BulkheadConfig holds the bulkhead settings, every route gets its own
+// compartment unless it belongs to one of Groups
+type BulkheadConfig struct {
+	// Maximum requests in flight per compartment
+	MaxConcurrent int // default 100
+
+	// Compartments shared by the routes under path prefixes, e.g. "/admin",
+	// with their maximum requests in flight, the longest prefix wins. Prefixes
+	// ignore case when Settings.CaseInSensitive is set.
+	Groups map[string]int // default nil
+
+	// Time a request waits for a free slot before it is shed
+	MaxWait time.Duration // default 0 (shed at once)
+
+	// Sent in the Retry-After header of shed requests
+	RetryAfter time.Duration // default 1 second
+
+	// Answers shed requests
+	Rejected func(ctx Context) // default 503 Service Unavailable
+}
+
+type bulkheadGroup struct {
+	prefix string
+	folded string // prefix matched against lowercased paths, see Settings.CaseInSensitive
+	slots  chan struct{}
+}
+
+// Bulkhead returns a middleware capping the requests in flight per route or
+// group, so a slow dependency cannot take all the connections of the server.
+// Shed requests are answered with 503 and Retry-After.
+func Bulkhead(config ...*BulkheadConfig) handlerFunc {
+	cfg := &BulkheadConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 100
+	}
+
+	retryAfter := strconv.Itoa(ceilSeconds(durationOr(cfg.RetryAfter, time.Second)))
+
+	rejected := cfg.Rejected
+	if rejected == nil {
+		rejected = func(ctx Context) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusServiceUnavailable),
+				fasthttp.StatusServiceUnavailable)
+		}
+	}
+
+	groups := make([]bulkheadGroup, 0, len(cfg.Groups))
+	for prefix, limit := range cfg.Groups {
+		if limit <= 0 {
+			limit = maxConcurrent
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		groups = append(groups, bulkheadGroup{
+			prefix: prefix,
+			folded: strings.ToLower(prefix),
+			slots:  make(chan struct{}, limit),
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return len(groups[i].prefix) > len(groups[j].prefix)
+	})
+
+	var mutex sync.Mutex
+	routes := make(map[string]chan struct{})
+
+	compartment := func(ctx Context) chan struct{} {
+		path, folded := routingPath(ctx)
+		for _, group := range groups {
+			prefix := group.prefix
+			if folded {
+				prefix = group.folded
+			}
+			if hasPathPrefix(path, prefix) {
+				return group.slots
+			}
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		slots, ok := routes[ctx.Route()]
+		if !ok {
+			slots = make(chan struct{}, maxConcurrent)
+			routes[ctx.Route()] = slots
+		}
+		return slots
+	}
+
+	return func(ctx Context) {
+		slots := compartment(ctx)
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			if !acquireSlot(slots, cfg.MaxWait) {
+				rejected(ctx)
+
+				// set after the handler, fasthttp errors reset the response headers
+				ctx.Set(HeaderRetryAfter, retryAfter)
+				return
+			}
+		}
+		defer func() { <-slots }()
+
+		ctx.Next()
+	}
+}
+
+// acquireSlot waits up to wait for a free slot
+func acquireSlot(slots chan struct{}, wait time.Duration) bool {
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
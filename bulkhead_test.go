@@ -0,0 +1,104 @@
+package godzilla
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestBulkhead tests shedding requests of full route and group compartments
+func TestBulkhead(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	blocking := func(ctx Context) {
+		entered <- struct{}{}
+		<-release
+	}
+
+	gz := setupGodzilla(&Settings{CaseInSensitive: true})
+	gz.Use(Bulkhead(&BulkheadConfig{
+		MaxConcurrent: 1,
+		Groups:        map[string]int{"/Admin": 1},
+		RetryAfter:    2 * time.Second,
+	}))
+	gz.Get("/slow", blocking)
+	gz.Get("/fast", pingHandler)
+	gz.Get("/admin/slow", blocking)
+	gz.Get("/admin/fast", pingHandler)
+	startGodzilla(gz)
+
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest(MethodGet, path, nil)
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	testCases := []struct {
+		blocked    string
+		path       string
+		statusCode int
+	}{
+		{blocked: "/slow", path: "/slow", statusCode: StatusServiceUnavailable},
+		{blocked: "/slow", path: "/fast", statusCode: StatusOK},
+		{blocked: "/slow", path: "/admin/fast", statusCode: StatusOK},
+		{blocked: "/admin/slow", path: "/admin/fast", statusCode: StatusServiceUnavailable},
+		{blocked: "/admin/slow", path: "/ADMIN/fast", statusCode: StatusServiceUnavailable},
+		{blocked: "/admin/slow", path: "/fast", statusCode: StatusOK},
+	}
+
+	for _, tc := range testCases {
+		done := make(chan int)
+		go func() {
+			done <- get(tc.blocked).StatusCode
+		}()
+		<-entered
+
+		response := get(tc.path)
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s blocked, %s returned %d expected %d", tc.blocked, tc.path, response.StatusCode, tc.statusCode)
+		}
+		if tc.statusCode == StatusServiceUnavailable && response.Header.Get(HeaderRetryAfter) != "2" {
+			t.Fatalf("%s returned Retry-After %q", tc.path, response.Header.Get(HeaderRetryAfter))
+		}
+
+		release <- struct{}{}
+		if status := <-done; status != StatusOK {
+			t.Fatalf("%s returned %d", tc.blocked, status)
+		}
+	}
+}
+
+// TestBulkheadWait tests requests waiting for a free slot
+func TestBulkheadWait(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	gz := setupGodzilla()
+	gz.Use(Bulkhead(&BulkheadConfig{MaxConcurrent: 1, MaxWait: time.Second}))
+	gz.Get("/slow", func(ctx Context) {
+		entered <- struct{}{}
+		time.Sleep(20 * time.Millisecond)
+	})
+	startGodzilla(gz)
+
+	done := make(chan int)
+	for i := 0; i < 2; i++ {
+		go func() {
+			req, _ := http.NewRequest(MethodGet, "/slow", nil)
+			response, err := makeRequest(req, gz)
+			if err != nil {
+				done <- 0
+				return
+			}
+			done <- response.StatusCode
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		<-entered
+		if status := <-done; status != StatusOK {
+			t.Fatalf("waiting request returned %d", status)
+		}
+	}
+}
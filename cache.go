@@ -0,0 +1,458 @@
+package godzilla
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Cache headers
+const (
+	HeaderCacheControl = "Cache-Control"
+	HeaderAge          = "Age"
+	HeaderXCache       = "X-Cache"
+)
+
+// Values of the X-Cache header
+const (
+	CacheHit   = "HIT"   // served from the cache
+	CacheMiss  = "MISS"  // served by the handlers
+	CacheStale = "STALE" // served from the cache while it is revalidated
+)
+
+// CacheConfig holds the response cache settings
+type CacheConfig struct {
+	// Lifetime of responses without max-age or s-maxage
+	TTL time.Duration // default 1 minute
+
+	// Time expired responses are served while they are revalidated in the
+	// background, responses may set their own with stale-while-revalidate
+	StaleWhileRevalidate time.Duration // default 0 (disabled)
+
+	// Maximum total size of cached responses in bytes, the least recently
+	// used responses are evicted first
+	MaxSize int // default 64 MB
+
+	// Request headers the responses vary on, they are part of the key, e.g. "Accept-Encoding".
+	// Responses whose Vary header lists other request headers, or "*", are not stored.
+	KeyHeaders []string // default nil
+
+	// Status codes of cached responses
+	StatusCodes []int // default 200, 203, 204, 300, 301, 404, 410
+
+	// Skip caching for some requests
+	Skip func(ctx Context) bool // default nil
+}
+
+// Cache returns a middleware caching full responses of GET and HEAD requests,
+// keyed by path, query and KeyHeaders. Cache-Control and Vary are honored,
+// concurrent misses of a key run the handlers once.
+func Cache(config ...*CacheConfig) handlerFunc {
+	cfg := &CacheConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	ttl := durationOr(cfg.TTL, time.Minute)
+
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = 64 << 20
+	}
+
+	statusCodes := make(map[int]bool)
+	for _, code := range cfg.StatusCodes {
+		statusCodes[code] = true
+	}
+	if len(statusCodes) == 0 {
+		for _, code := range []int{StatusOK, StatusNonAuthoritativeInfo, StatusNoContent,
+			StatusMultipleChoices, StatusMovedPermanently, StatusNotFound, StatusGone} {
+			statusCodes[code] = true
+		}
+	}
+
+	keyHeaders := append([]string(nil), cfg.KeyHeaders...)
+	sort.Strings(keyHeaders)
+
+	keyed := make(map[string]bool, len(keyHeaders))
+	for _, name := range keyHeaders {
+		keyed[strings.ToLower(name)] = true
+	}
+
+	// varies reports whether the response varies on request headers that are
+	// not part of the key, a single entry cannot serve every client then
+	varies := func(resp *fasthttp.Response) bool {
+		uncovered := false
+		resp.Header.VisitAll(func(k, v []byte) {
+			if !strings.EqualFold(GetString(k), HeaderVary) {
+				return
+			}
+			for _, name := range strings.Split(GetString(v), ",") {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "*" || (name != "" && !keyed[name]) {
+					uncovered = true
+				}
+			}
+		})
+		return uncovered
+	}
+
+	store := newCacheStore(maxSize)
+
+	// lifetimes returns how long the response may be served fresh and then
+	// stale, ok is false for responses that must not be stored
+	lifetimes := func(ctx Context) (fresh, stale time.Duration, ok bool) {
+		resp := &ctx.Context().Response
+		if !statusCodes[resp.StatusCode()] || resp.IsBodyStream() || len(resp.Header.Peek("Set-Cookie")) > 0 {
+			return 0, 0, false
+		}
+		if varies(resp) {
+			return 0, 0, false
+		}
+
+		directives := parseCacheControl(GetString(resp.Header.Peek(HeaderCacheControl)))
+		if _, ok := directives["no-store"]; ok {
+			return 0, 0, false
+		}
+		if _, ok := directives["private"]; ok {
+			return 0, 0, false
+		}
+		if _, ok := directives["no-cache"]; ok {
+			return 0, 0, false
+		}
+
+		// responses to authorized requests are personal unless marked shareable
+		_, public := directives["public"]
+		_, shared := directives["s-maxage"]
+		if len(ctx.Context().Request.Header.Peek(HeaderAuthorization)) > 0 && !public && !shared {
+			return 0, 0, false
+		}
+
+		fresh, stale = ttl, cfg.StaleWhileRevalidate
+		if seconds, ok := directives["s-maxage"]; ok {
+			fresh = parseSeconds(seconds)
+		} else if seconds, ok := directives["max-age"]; ok {
+			fresh = parseSeconds(seconds)
+		}
+		if seconds, ok := directives["stale-while-revalidate"]; ok {
+			stale = parseSeconds(seconds)
+		}
+		return fresh, stale, fresh > 0 || stale > 0
+	}
+
+	// save stores the response of the handlers, it returns nil when the
+	// response cannot be cached
+	save := func(ctx Context, key string) *cacheEntry {
+		fresh, stale, ok := lifetimes(ctx)
+		if !ok {
+			return nil
+		}
+
+		entry := newCacheEntry(key, &ctx.Context().Response)
+		entry.expires = entry.stored.Add(fresh)
+		entry.staleUntil = entry.expires.Add(stale)
+		store.set(entry)
+		return entry
+	}
+
+	return func(ctx Context) {
+		fctx := ctx.Context()
+		method := GetString(fctx.Method())
+		if (method != MethodGet && method != MethodHead) || (cfg.Skip != nil && cfg.Skip(ctx)) {
+			ctx.Next()
+			return
+		}
+
+		directives := parseCacheControl(ctx.Get(HeaderCacheControl))
+		if _, ok := directives["no-store"]; ok {
+			ctx.Next()
+			return
+		}
+
+		key := method + " " + cacheKey(ctx, keyHeaders)
+
+		// background revalidations refresh the entry
+		if revalidating, _ := ctx.GetLocal(localCacheRevalidation).(bool); revalidating {
+			ctx.Next()
+			save(ctx, key)
+			return
+		}
+
+		// no-cache and max-age=0 ask for a fresh response
+		_, noCache := directives["no-cache"]
+		if maxAge, ok := directives["max-age"]; ok && parseSeconds(maxAge) == 0 {
+			noCache = true
+		}
+
+		now := time.Now()
+		if !noCache {
+			entry := store.get(key, now)
+			if entry == nil && method == MethodHead {
+				// the response of a GET request tells about the one of HEAD
+				entry = store.get(MethodGet+" "+cacheKey(ctx, keyHeaders), now)
+			}
+			if entry != nil {
+				if now.Before(entry.expires) {
+					entry.write(fctx, CacheHit, now)
+					return
+				}
+				if store.startRevalidation(entry) {
+					revalidate(ctx, entry, store)
+				}
+				entry.write(fctx, CacheStale, now)
+				return
+			}
+		}
+
+		// concurrent misses wait for the first one
+		call, leader := store.join(key)
+		if !leader {
+			<-call.done
+			if call.entry != nil {
+				call.entry.write(fctx, CacheHit, time.Now())
+				return
+			}
+
+			// the response could not be cached, e.g. it was an error
+			ctx.Next()
+			ctx.Set(HeaderXCache, CacheMiss)
+			return
+		}
+		defer store.leave(key, call)
+
+		ctx.Next()
+		call.entry = save(ctx, key)
+		ctx.Set(HeaderXCache, CacheMiss)
+	}
+}
+
+const localCacheRevalidation = "godzilla.cache.revalidation"
+
+// revalidate runs the handlers for a copy of the request in the background,
+// the cache middleware stores the new response
+func revalidate(ctx Context, entry *cacheEntry, store *cacheStore) {
+	c, ok := ctx.(*context)
+	if !ok || c.router == nil {
+		store.endRevalidation(entry)
+		return
+	}
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Init(&ctx.Context().Request, ctx.Context().RemoteAddr(), nil)
+	fctx.SetUserValue(localCacheRevalidation, true)
+
+	router := c.router
+	go func() {
+		defer store.endRevalidation(entry)
+		router.Handler(fctx)
+	}()
+}
+
+// cacheKey joins the path, query and key headers of the request
+func cacheKey(ctx Context, keyHeaders []string) string {
+	fctx := ctx.Context()
+
+	var sb strings.Builder
+	sb.Write(fctx.Path())
+	if query := fctx.URI().QueryString(); len(query) > 0 {
+		sb.WriteByte('?')
+		sb.Write(query)
+	}
+	for _, name := range keyHeaders {
+		sb.WriteByte('\x00')
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.Write(fctx.Request.Header.Peek(name))
+	}
+	return sb.String()
+}
+
+// parseCacheControl returns the directives of a Cache-Control header, names
+// are lower case
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cacheEntry is a stored response
+type cacheEntry struct {
+	key        string
+	status     int
+	headers    []cacheHeader
+	body       []byte
+	size       int
+	stored     time.Time
+	expires    time.Time
+	staleUntil time.Time
+
+	element      *list.Element
+	revalidating bool
+}
+
+type cacheHeader struct {
+	key, value string
+}
+
+func newCacheEntry(key string, resp *fasthttp.Response) *cacheEntry {
+	entry := &cacheEntry{
+		key:    key,
+		status: resp.StatusCode(),
+		body:   append([]byte(nil), resp.Body()...),
+		stored: time.Now(),
+	}
+
+	resp.Header.VisitAll(func(k, v []byte) {
+		switch string(k) {
+		case "Date", "Connection", HeaderXCache, HeaderAge, HeaderXRequestID:
+			return
+		}
+		entry.headers = append(entry.headers, cacheHeader{key: string(k), value: string(v)})
+		entry.size += len(k) + len(v)
+	})
+	entry.size += len(key) + len(entry.body)
+	return entry
+}
+
+// write sends the stored response, headers set by earlier middlewares are
+// kept unless the response has its own
+func (e *cacheEntry) write(fctx *fasthttp.RequestCtx, status string, now time.Time) {
+	resp := &fctx.Response
+	resp.SetStatusCode(e.status)
+	for _, h := range e.headers {
+		resp.Header.Del(h.key)
+	}
+	for _, h := range e.headers {
+		resp.Header.Add(h.key, h.value)
+	}
+	resp.SetBody(e.body)
+
+	resp.Header.Set(HeaderAge, strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	resp.Header.Set(HeaderXCache, status)
+}
+
+// cacheCall is a running miss other requests of the key wait for
+type cacheCall struct {
+	done  chan struct{}
+	entry *cacheEntry
+}
+
+// cacheStore keeps entries in least recently used order
+type cacheStore struct {
+	mutex    sync.Mutex
+	entries  map[string]*cacheEntry
+	lru      *list.List // most recently used first
+	size     int
+	maxSize  int
+	inflight map[string]*cacheCall
+}
+
+func newCacheStore(maxSize int) *cacheStore {
+	return &cacheStore{
+		entries:  make(map[string]*cacheEntry),
+		lru:      list.New(),
+		maxSize:  maxSize,
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// get returns the entry of key while it may be served, fresh or stale
+func (s *cacheStore) get(key string, now time.Time) *cacheEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.staleUntil) {
+		s.remove(entry)
+		return nil
+	}
+	s.lru.MoveToFront(entry.element)
+	return entry
+}
+
+func (s *cacheStore) set(entry *cacheEntry) {
+	if entry.size > s.maxSize {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if prior, ok := s.entries[entry.key]; ok {
+		s.remove(prior)
+	}
+
+	entry.element = s.lru.PushFront(entry)
+	s.entries[entry.key] = entry
+	s.size += entry.size
+
+	for s.size > s.maxSize {
+		s.remove(s.lru.Back().Value.(*cacheEntry))
+	}
+}
+
+func (s *cacheStore) remove(entry *cacheEntry) {
+	s.lru.Remove(entry.element)
+	delete(s.entries, entry.key)
+	s.size -= entry.size
+}
+
+// startRevalidation reports whether the caller should revalidate entry, only
+// one revalidation runs at a time
+func (s *cacheStore) startRevalidation(entry *cacheEntry) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry.revalidating {
+		return false
+	}
+	entry.revalidating = true
+	return true
+}
+
+func (s *cacheStore) endRevalidation(entry *cacheEntry) {
+	s.mutex.Lock()
+	entry.revalidating = false
+	s.mutex.Unlock()
+}
+
+// join returns the running miss of key, leader is set when the caller started it
+func (s *cacheStore) join(key string) (call *cacheCall, leader bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if call, ok := s.inflight[key]; ok {
+		return call, false
+	}
+	call = &cacheCall{done: make(chan struct{})}
+	s.inflight[key] = call
+	return call, true
+}
+
+// leave ends the miss of key, waiting requests are released
+func (s *cacheStore) leave(key string, call *cacheCall) {
+	s.mutex.Lock()
+	delete(s.inflight, key)
+	s.mutex.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,193 @@
+package godzilla
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCache tests hits, misses and Cache-Control handling
+func TestCache(t *testing.T) {
+	var calls int32
+	counter := func(ctx Context) {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.Set("X-Call", strconv.Itoa(int(n)))
+		ctx.SendString(string(ctx.Context().Path()) + " " + strconv.Itoa(int(n)))
+	}
+
+	gz := setupGodzilla()
+	gz.Use(Cache(&CacheConfig{KeyHeaders: []string{"Accept-Language"}, TTL: time.Hour}))
+	gz.Get("/counter", counter)
+	gz.Head("/counter", counter)
+	gz.Post("/counter", counter)
+	gz.Get("/private", func(ctx Context) {
+		ctx.Set(HeaderCacheControl, "private, max-age=60")
+		counter(ctx)
+	})
+	gz.Get("/error", func(ctx Context) {
+		ctx.Status(StatusInternalServerError)
+		counter(ctx)
+	})
+	gz.Get("/vary/:header", func(ctx Context) {
+		ctx.Set(HeaderVary, ctx.Param("header"))
+		counter(ctx)
+	})
+	startGodzilla(gz)
+
+	testCases := []struct {
+		method  string
+		path    string
+		headers map[string]string
+		cache   string
+		body    string
+	}{
+		{method: MethodGet, path: "/counter", cache: CacheMiss, body: "/counter 1"},
+		{method: MethodGet, path: "/counter", cache: CacheHit, body: "/counter 1"},
+		{method: MethodHead, path: "/counter", cache: CacheHit},
+		{method: MethodGet, path: "/counter?page=2", cache: CacheMiss, body: "/counter 2"},
+		{method: MethodGet, path: "/counter", headers: map[string]string{"Accept-Language": "de"}, cache: CacheMiss, body: "/counter 3"},
+		{method: MethodGet, path: "/counter", headers: map[string]string{HeaderCacheControl: "no-cache"}, cache: CacheMiss, body: "/counter 4"},
+		{method: MethodGet, path: "/counter", cache: CacheHit, body: "/counter 4"},
+		{method: MethodGet, path: "/counter", headers: map[string]string{HeaderCacheControl: "no-store"}, cache: "", body: "/counter 5"},
+		{method: MethodPost, path: "/counter", cache: "", body: "/counter 6"},
+		{method: MethodGet, path: "/private", cache: CacheMiss, body: "/private 7"},
+		{method: MethodGet, path: "/private", cache: CacheMiss, body: "/private 8"},
+		{method: MethodGet, path: "/error", cache: CacheMiss, body: "/error 9"},
+		{method: MethodGet, path: "/error", cache: CacheMiss, body: "/error 10"},
+		{method: MethodGet, path: "/vary/accept-language", cache: CacheMiss, body: "/vary/accept-language 11"},
+		{method: MethodGet, path: "/vary/accept-language", cache: CacheHit, body: "/vary/accept-language 11"},
+		{method: MethodGet, path: "/vary/*", cache: CacheMiss, body: "/vary/* 12"},
+		{method: MethodGet, path: "/vary/*", cache: CacheMiss, body: "/vary/* 13"},
+		{method: MethodGet, path: "/vary/Origin", cache: CacheMiss, body: "/vary/Origin 14"},
+		{method: MethodGet, path: "/vary/Origin", cache: CacheMiss, body: "/vary/Origin 15"},
+	}
+
+	for i, tc := range testCases {
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		for key, value := range tc.headers {
+			req.Header.Set(key, value)
+		}
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		if response.Header.Get(HeaderXCache) != tc.cache || (tc.method != MethodHead && string(body) != tc.body) {
+			t.Fatalf("%d %s %s: returned %s %q expected %s %q", i, tc.method, tc.path, response.Header.Get(HeaderXCache), body, tc.cache, tc.body)
+		}
+		if tc.cache == CacheHit && response.Header.Get("X-Call") == "" {
+			t.Fatalf("%d %s %s: returned no cached headers", i, tc.method, tc.path)
+		}
+	}
+}
+
+// TestCacheEviction tests the least recently used responses are evicted
+func TestCacheEviction(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(Cache(&CacheConfig{MaxSize: 500}))
+	gz.Get("/:name", func(ctx Context) {
+		ctx.SendString(strings.Repeat(ctx.Param("name"), 100))
+	})
+	startGodzilla(gz)
+
+	get := func(path string) string {
+		req, _ := http.NewRequest(MethodGet, path, nil)
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.Header.Get(HeaderXCache)
+	}
+
+	// each entry takes about 160 bytes, three of them fit
+	for _, path := range []string{"/a", "/b", "/a", "/c", "/d"} {
+		get(path)
+	}
+	if get("/a") != CacheHit || get("/b") != CacheMiss {
+		t.Fatalf("evicted recently used entries")
+	}
+}
+
+// TestCacheStale tests stale responses are served while one revalidation runs
+func TestCacheStale(t *testing.T) {
+	var calls int32
+	gz := setupGodzilla()
+	gz.Use(Cache())
+	gz.Get("/stale", func(ctx Context) {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.Set(HeaderCacheControl, "max-age=1, stale-while-revalidate=60")
+		ctx.SendString(strconv.Itoa(int(n)))
+	})
+	startGodzilla(gz)
+
+	get := func() (string, string) {
+		req, _ := http.NewRequest(MethodGet, "/stale", nil)
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		return response.Header.Get(HeaderXCache), string(body)
+	}
+
+	get()
+	time.Sleep(1100 * time.Millisecond)
+
+	if cache, body := get(); cache != CacheStale || body != "1" {
+		t.Fatalf("returned %s %q expected stale response", cache, body)
+	}
+
+	// the background revalidation refreshed the entry
+	for i := 0; i < 100 && atomic.LoadInt32(&calls) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if cache, body := get(); cache != CacheHit || body != "2" {
+		t.Fatalf("returned %s %q expected revalidated response", cache, body)
+	}
+}
+
+// TestCacheCoalescing tests concurrent misses run the handlers once
+func TestCacheCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	gz := setupGodzilla()
+	gz.Use(Cache())
+	gz.Get("/slow", func(ctx Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ctx.SendString("done")
+	})
+	startGodzilla(gz)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(MethodGet, "/slow", nil)
+			response, err := makeRequest(req, gz)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if body, _ := ioutil.ReadAll(response.Body); string(body) != "done" {
+				t.Errorf("returned %q", body)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times", calls)
+	}
+}
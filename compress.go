@@ -0,0 +1,294 @@
+package godzilla
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+// Content encodings
+const (
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// Compression headers
+const (
+	HeaderAcceptEncoding  = "Accept-Encoding"
+	HeaderContentEncoding = "Content-Encoding"
+)
+
+// CompressConfig holds the compression middleware settings, levels left at 0
+// or out of range use the default level of the encoding
+type CompressConfig struct {
+	// Supported encodings, the first one wins when the client accepts several
+	// with the same quality
+	Encodings []string // default ["br", "zstd", "gzip", "deflate"]
+
+	// Bodies smaller than MinLength bytes are sent as is
+	MinLength int // default 1024
+
+	// Content type prefixes that are never compressed
+	ExcludedTypes []string // default DefaultExcludedTypes
+
+	BrotliLevel  int // default 6, from 1 to 11
+	ZstdLevel    int // default 3, from 1 to 22
+	GzipLevel    int // default 6, from 1 to 9
+	DeflateLevel int // default 6, from 1 to 9
+
+	// Requests for which Skip returns true are not compressed
+	Skip func(ctx Context) bool // default nil
+}
+
+// DefaultExcludedTypes are content types that are already compressed
+var DefaultExcludedTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/zstd", "application/x-brotli", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/pdf",
+}
+
+// Compress returns a middleware compressing response bodies with the best
+// encoding accepted by the client. Streamed bodies are compressed on the fly
+// by fasthttp, which supports every encoding but zstd.
+func Compress(config ...*CompressConfig) handlerFunc {
+	cfg := &CompressConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	encodings := cfg.Encodings
+	if len(encodings) == 0 {
+		encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+	}
+
+	minLength := cfg.MinLength
+	if minLength <= 0 {
+		minLength = 1024
+	}
+
+	excluded := cfg.ExcludedTypes
+	if excluded == nil {
+		excluded = DefaultExcludedTypes
+	}
+
+	c := newCompressors(cfg)
+
+	return func(ctx Context) {
+		ctx.Next()
+
+		if cfg.Skip != nil && cfg.Skip(ctx) {
+			return
+		}
+
+		fctx := ctx.Context()
+		if fctx.LastTimeoutErrorResponse() != nil {
+			return
+		}
+		resp := &fctx.Response
+
+		if fctx.IsHead() || resp.StatusCode() == StatusNoContent || resp.StatusCode() == StatusNotModified ||
+			len(resp.Header.Peek(HeaderContentEncoding)) > 0 || excludedType(excluded, resp.Header.ContentType()) {
+			return
+		}
+
+		stream := resp.IsBodyStream()
+		if !stream && len(resp.Body()) < minLength {
+			return
+		}
+
+		resp.Header.Add(HeaderVary, HeaderAcceptEncoding)
+
+		encoding := negotiateEncoding(ctx.Get(HeaderAcceptEncoding), encodings, stream)
+		if encoding == "" {
+			return
+		}
+
+		if stream {
+			c.compressStream(fctx, encoding)
+			return
+		}
+
+		body, err := c.compress(encoding, resp.Body())
+		if err != nil {
+			requestLogger(ctx).Error("compressing response failed", "encoding", encoding, "error", err)
+			return
+		}
+
+		resp.SetBodyRaw(body)
+		resp.Header.Set(HeaderContentEncoding, encoding)
+	}
+}
+
+// compressors keeps pooled writers of every encoding
+type compressors struct {
+	brotliLevel  int
+	gzipLevel    int
+	deflateLevel int
+
+	brotli  sync.Pool
+	gzip    sync.Pool
+	deflate sync.Pool
+	zstd    *zstd.Encoder
+
+	// fasthttp compression of streamed bodies, by encoding
+	streams map[string]fasthttp.RequestHandler
+}
+
+func newCompressors(cfg *CompressConfig) *compressors {
+	c := &compressors{
+		brotliLevel:  levelOr(cfg.BrotliLevel, brotli.DefaultCompression, brotli.BestSpeed, brotli.BestCompression),
+		gzipLevel:    levelOr(cfg.GzipLevel, gzip.DefaultCompression, gzip.BestSpeed, gzip.BestCompression),
+		deflateLevel: levelOr(cfg.DeflateLevel, flate.DefaultCompression, flate.BestSpeed, flate.BestCompression),
+	}
+
+	// EncodeAll is safe for concurrent use, the encoder is shared
+	c.zstd, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(levelOr(cfg.ZstdLevel, 3, 1, 22))))
+
+	noop := func(*fasthttp.RequestCtx) {}
+	c.streams = map[string]fasthttp.RequestHandler{
+		EncodingBrotli:  fasthttp.CompressHandlerBrotliLevel(noop, c.brotliLevel, c.gzipLevel),
+		EncodingGzip:    fasthttp.CompressHandlerBrotliLevel(noop, c.brotliLevel, c.gzipLevel),
+		EncodingDeflate: fasthttp.CompressHandlerBrotliLevel(noop, c.brotliLevel, c.deflateLevel),
+	}
+	return c
+}
+
+func (c *compressors) compress(encoding string, body []byte) ([]byte, error) {
+	if encoding == EncodingZstd {
+		return c.zstd.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(body) / 2)
+
+	w, pool := c.writer(encoding, &buf)
+	defer pool.Put(w)
+
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resetWriter is implemented by the pooled writers
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+func (c *compressors) writer(encoding string, out io.Writer) (resetWriter, *sync.Pool) {
+	var pool *sync.Pool
+	var w resetWriter
+
+	switch encoding {
+	case EncodingBrotli:
+		pool = &c.brotli
+		if v := pool.Get(); v != nil {
+			w = v.(resetWriter)
+		} else {
+			w = brotli.NewWriterLevel(out, c.brotliLevel)
+		}
+	case EncodingGzip:
+		pool = &c.gzip
+		if v := pool.Get(); v != nil {
+			w = v.(resetWriter)
+		} else {
+			// levels are validated by newCompressors
+			w, _ = gzip.NewWriterLevel(out, c.gzipLevel)
+		}
+	default:
+		pool = &c.deflate
+		if v := pool.Get(); v != nil {
+			w = v.(resetWriter)
+		} else {
+			w, _ = flate.NewWriter(out, c.deflateLevel)
+		}
+	}
+
+	w.Reset(out)
+	return w, pool
+}
+
+// compressStream lets fasthttp compress a streamed body with encoding, it
+// picks the encoding from Accept-Encoding so the header is narrowed meanwhile
+func (c *compressors) compressStream(fctx *fasthttp.RequestCtx, encoding string) {
+	accept := string(fctx.Request.Header.Peek(HeaderAcceptEncoding))
+
+	fctx.Request.Header.Set(HeaderAcceptEncoding, encoding)
+	c.streams[encoding](fctx)
+	fctx.Request.Header.Set(HeaderAcceptEncoding, accept)
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality in
+// accept, ties are broken by the order of supported
+func negotiateEncoding(accept string, supported []string, stream bool) string {
+	if accept == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params := part, ""
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name, params = part[:i], part[i+1:]
+		}
+
+		q := 1.0
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			if v, err := strconv.ParseFloat(params[2:], 64); err == nil {
+				q = v
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		if stream && encoding == EncodingZstd {
+			continue
+		}
+
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func excludedType(excluded []string, contentType []byte) bool {
+	for _, prefix := range excluded {
+		if strings.HasPrefix(GetString(contentType), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// levelOr returns level, or def when level is 0 or out of [min, max]
+func levelOr(level, def, min, max int) int {
+	if level == 0 || level < min || level > max {
+		return def
+	}
+	return level
+}
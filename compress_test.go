@@ -0,0 +1,134 @@
+package godzilla
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+var compressBody = strings.Repeat("godzilla compresses responses. ", 100)
+
+// TestCompress tests encoding negotiation and skipped responses
+func TestCompress(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(Compress())
+	gz.Get("/text", func(ctx Context) {
+		ctx.SendString(compressBody)
+	})
+	gz.Get("/small", pingHandler)
+	gz.Get("/image", func(ctx Context) {
+		ctx.Context().SetContentType("image/png")
+		ctx.SendString(compressBody)
+	})
+	gz.Get("/stream", func(ctx Context) {
+		ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			w.WriteString(compressBody)
+		})
+	})
+	startGodzilla(gz)
+
+	testCases := []struct {
+		path     string
+		accept   string
+		encoding string
+		vary     bool
+	}{
+		{path: "/text", accept: "gzip, deflate, br", encoding: EncodingBrotli, vary: true},
+		{path: "/text", accept: "gzip, br;q=0.5", encoding: EncodingGzip, vary: true},
+		{path: "/text", accept: "zstd", encoding: EncodingZstd, vary: true},
+		{path: "/text", accept: "deflate", encoding: EncodingDeflate, vary: true},
+		{path: "/text", accept: "*;q=0.1, br;q=0", encoding: EncodingZstd, vary: true},
+		{path: "/text", accept: "identity", vary: true},
+		{path: "/text", vary: true},
+		{path: "/small", accept: "gzip"},
+		{path: "/image", accept: "gzip"},
+		{path: "/stream", accept: "zstd, gzip;q=0.9", encoding: EncodingGzip, vary: true},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, tc.path, nil)
+		if tc.accept != "" {
+			req.Header.Set(HeaderAcceptEncoding, tc.accept)
+		}
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.path, tc.accept, err.Error())
+		}
+
+		if encoding := response.Header.Get(HeaderContentEncoding); encoding != tc.encoding {
+			t.Fatalf("%s(%s): returned encoding %q expected %q", tc.path, tc.accept, encoding, tc.encoding)
+		}
+
+		if vary := response.Header.Get(HeaderVary) == HeaderAcceptEncoding; vary != tc.vary {
+			t.Fatalf("%s(%s): returned vary %v expected %v", tc.path, tc.accept, vary, tc.vary)
+		}
+
+		body, err := decodeBody(tc.encoding, response.Body)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.path, tc.accept, err.Error())
+		}
+
+		if tc.path != "/small" && string(body) != compressBody {
+			t.Fatalf("%s(%s): returned body of %d bytes expected %d", tc.path, tc.accept, len(body), len(compressBody))
+		}
+	}
+}
+
+func decodeBody(encoding string, body io.Reader) ([]byte, error) {
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = bytes.NewReader(raw)
+	switch encoding {
+	case EncodingBrotli:
+		r = brotli.NewReader(r)
+	case EncodingGzip:
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, err
+		}
+	case EncodingDeflate:
+		r = flate.NewReader(r)
+	case EncodingZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		r = d
+	}
+	return ioutil.ReadAll(r)
+}
+
+// TestCompressLevels tests levels left at 0 or out of range use the default
+func TestCompressLevels(t *testing.T) {
+	testCases := []struct {
+		config  CompressConfig
+		brotli  int
+		gzip    int
+		deflate int
+	}{
+		{config: CompressConfig{}, brotli: brotli.DefaultCompression, gzip: gzip.DefaultCompression, deflate: flate.DefaultCompression},
+		{config: CompressConfig{BrotliLevel: 1, GzipLevel: 1, DeflateLevel: 9}, brotli: 1, gzip: 1, deflate: 9},
+		{config: CompressConfig{BrotliLevel: 11, GzipLevel: 10, DeflateLevel: -1}, brotli: 11, gzip: gzip.DefaultCompression, deflate: flate.DefaultCompression},
+		{config: CompressConfig{BrotliLevel: 12}, brotli: brotli.DefaultCompression, gzip: gzip.DefaultCompression, deflate: flate.DefaultCompression},
+	}
+
+	for i, tc := range testCases {
+		c := newCompressors(&tc.config)
+		if c.brotliLevel != tc.brotli || c.gzipLevel != tc.gzip || c.deflateLevel != tc.deflate {
+			t.Fatalf("%d: levels %d %d %d expected %d %d %d", i, c.brotliLevel, c.gzipLevel, c.deflateLevel, tc.brotli, tc.gzip, tc.deflate)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package godzilla
+
+import "net"
+
+// watchConn does not notice closed connections on this platform
+func watchConn(conn net.Conn, closed func()) (stop func()) {
+	return func() {}
+}
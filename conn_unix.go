@@ -0,0 +1,70 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package godzilla
+
+import (
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchConn calls closed once the client closed or reset conn, until the
+// returned stop is called. stop waits for the watch to end, the server can
+// read the next request afterwards. Only the socket is peeked, bytes of a
+// pipelined request end the watch without being consumed.
+func watchConn(conn net.Conn, closed func()) (stop func()) {
+	// wrappers such as tls.Conn or proxyConn expose the socket with NetConn
+	for {
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = wrapper.NetConn()
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	var stopped int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var eof bool
+		var buf [1]byte
+		_ = raw.Read(func(fd uintptr) bool {
+			if atomic.LoadInt32(&stopped) == 1 {
+				return true
+			}
+			n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				// wait until the socket is readable
+				return false
+			}
+			eof = n == 0 && err == nil || err == syscall.ECONNRESET
+			return true
+		})
+
+		if eof && atomic.LoadInt32(&stopped) == 0 {
+			closed()
+		}
+	}()
+
+	return func() {
+		atomic.StoreInt32(&stopped, 1)
+
+		// a past deadline wakes the watch, the server sets its own deadline
+		// before reading the next request when it has read or idle timeouts
+		_ = conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+}
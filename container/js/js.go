@@ -19,10 +19,59 @@ limitations under the License.
 
 package js
 
+import "context"
+
 type VM interface {
 	// script(path, script) functioalities
 	Script(path, script string) error
 
 	// eval(path, expression) functionalities
 	Eval(path, expression string) (string, error)
+
+	// evalContext(ctx, path, expression) waits for promises until ctx is done
+	EvalContext(ctx context.Context, path, expression string) (string, error)
+
+	// decode(ctx, path, expression, out) decodes the result into out through json
+	Decode(ctx context.Context, path, expression string, out interface{}) error
+
+	// bind(name, value) exposes go functions and objects to scripts
+	Bind(name string, value interface{}) error
+}
+
+// Request is the request object passed to javascript handlers
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Params  map[string]string `json:"params"`
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// Response is the response object returned by javascript handlers, a body
+// that is not a string is sent as json
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    interface{}       `json:"body"`
+}
+
+type Runtime interface {
+	// handle(ctx, script, request) calls the function exported by script,
+	// a nil response means the script did not answer the request
+	Handle(ctx context.Context, script string, req *Request) (*Response, error)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the id of the request scripts run
+// for, vms attach it to the console entries of the evaluation
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request id carried by ctx, if any
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
 }
@@ -0,0 +1,202 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/bind.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	jsoniter "github.com/json-iterator/go"
+	"rogchap.com/v8go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Decode evaluates expr like EvalContext and decodes the result into out
+// through JSON. Pass a *interface{} to get plain Go values.
+func (vm *VM) Decode(ctx context.Context, path, expr string, out interface{}) error {
+	value, err := vm.evalContext(ctx, path, expr)
+	if err != nil {
+		return err
+	}
+
+	if value.IsUndefined() {
+		return nil
+	}
+
+	raw, err := v8go.JSONStringify(vm.context, value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(raw), out)
+}
+
+// Bind exposes value to scripts under the dotted name, e.g. "db.query".
+// Functions are called with their arguments decoded from JSON, an optional
+// leading context.Context receives the evaluation context and a trailing
+// error result is thrown as a javascript Error. Values with exported methods
+// become objects of bound methods named in lowerCamelCase, anything else is
+// copied in as JSON.
+func (vm *VM) Bind(name string, value interface{}) error {
+	bound, err := vm.bindValue(name, reflect.ValueOf(value))
+	if err != nil {
+		return err
+	}
+
+	segments := strings.Split(name, ".")
+	parent := vm.context.Global()
+	for _, segment := range segments[:len(segments)-1] {
+		child, err := parent.Get(segment)
+		if err != nil {
+			return err
+		}
+
+		if !child.IsObject() {
+			object, err := v8go.NewObjectTemplate(vm.isolate).NewInstance(vm.context)
+			if err != nil {
+				return err
+			}
+			if err := parent.Set(segment, object); err != nil {
+				return err
+			}
+			child = object.Value
+		}
+		parent = child.Object()
+	}
+	return parent.Set(segments[len(segments)-1], bound)
+}
+
+func (vm *VM) bindValue(name string, value reflect.Value) (*v8go.Value, error) {
+	if value.Kind() == reflect.Func {
+		return vm.bindFunc(name, value)
+	}
+
+	if value.IsValid() && value.NumMethod() > 0 {
+		object, err := v8go.NewObjectTemplate(vm.isolate).NewInstance(vm.context)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < value.NumMethod(); i++ {
+			method := value.Type().Method(i)
+			fn, err := vm.bindFunc(name+"."+method.Name, value.Method(i))
+			if err != nil {
+				return nil, err
+			}
+			if err := object.Set(lowerCamel(method.Name), fn); err != nil {
+				return nil, err
+			}
+		}
+		return object.Value, nil
+	}
+
+	return vm.toValue(value.Interface())
+}
+
+func (vm *VM) bindFunc(name string, fn reflect.Value) (*v8go.Value, error) {
+	fnType := fn.Type()
+	if fnType.IsVariadic() {
+		return nil, fmt.Errorf("v8: cannot bind variadic function %s", name)
+	}
+
+	numOut := fnType.NumOut()
+	returnsErr := numOut > 0 && fnType.Out(numOut-1) == errorType
+	if numOut > 2 || (numOut == 2 && !returnsErr) {
+		return nil, fmt.Errorf("v8: bound function %s must return (T), (error) or (T, error)", name)
+	}
+
+	withContext := fnType.NumIn() > 0 && fnType.In(0) == contextType
+
+	callback := func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		args := info.Args()
+		in := make([]reflect.Value, fnType.NumIn())
+
+		for i := range in {
+			argType := fnType.In(i)
+			if i == 0 && withContext {
+				in[i] = reflect.ValueOf(vm.evalCtx())
+				continue
+			}
+
+			arg := reflect.New(argType)
+			if j := i - boolToInt(withContext); j < len(args) && !args[j].IsNullOrUndefined() {
+				raw, err := v8go.JSONStringify(info.Context(), args[j])
+				if err != nil {
+					return throw(info.Context(), err.Error())
+				}
+				if err := json.Unmarshal([]byte(raw), arg.Interface()); err != nil {
+					return throw(info.Context(), fmt.Sprintf("%s: argument %d: %s", name, j, err))
+				}
+			}
+			in[i] = arg.Elem()
+		}
+
+		out := fn.Call(in)
+
+		if returnsErr {
+			if err, _ := out[numOut-1].Interface().(error); err != nil {
+				return throw(info.Context(), err.Error())
+			}
+			out = out[:numOut-1]
+		}
+
+		if len(out) == 0 {
+			return nil
+		}
+
+		value, err := vm.toValue(out[0].Interface())
+		if err != nil {
+			return throw(info.Context(), err.Error())
+		}
+		return value
+	}
+
+	return v8go.NewFunctionTemplate(vm.isolate, callback).GetFunction(vm.context).Value, nil
+}
+
+// toValue converts in to a javascript value through JSON
+func (vm *VM) toValue(in interface{}) (*v8go.Value, error) {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return v8go.JSONParse(vm.context, string(raw))
+}
+
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package v8
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type greeter struct {
+	greeting string
+}
+
+func (g *greeter) Greet(name string) string {
+	return g.greeting + " " + name
+}
+
+type ctxKey struct{}
+
+// TestBind tests calling bound functions and methods from scripts
+func TestBind(t *testing.T) {
+	vm, err := Load(&Settings{
+		Bindings: map[string]interface{}{
+			"config": map[string]int{"port": 8080},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	bindings := map[string]interface{}{
+		"math.add": func(a, b int) int { return a + b },
+		"fail": func() error {
+			return errors.New("bound failure")
+		},
+		"lookup": func(ctx context.Context, key string) (string, error) {
+			value, _ := ctx.Value(ctxKey{}).(string)
+			return value + " " + key, nil
+		},
+		"greeter": &greeter{greeting: "hello"},
+	}
+	for name, value := range bindings {
+		if err := vm.Bind(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "ctx")
+
+	testCases := []struct {
+		expr   string
+		result string
+		err    string
+	}{
+		{expr: "math.add(1, 2)", result: "3"},
+		{expr: "config.port", result: "8080"},
+		{expr: "greeter.greet('godzilla')", result: "hello godzilla"},
+		{expr: "lookup('key')", result: "ctx key"},
+		{expr: "fail()", err: "bound failure"},
+		{expr: "math.add('a', 2)", err: "math.add: argument 0"},
+	}
+
+	for _, tc := range testCases {
+		result, err := vm.EvalContext(ctx, "bind.js", tc.expr)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%s: returned error %v expected %q", tc.expr, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || result != tc.result {
+			t.Fatalf("%s: returned %q, %v expected %q", tc.expr, result, err, tc.result)
+		}
+	}
+
+	if err := vm.Bind("variadic", func(args ...int) {}); err == nil {
+		t.Fatal("binding a variadic function returned no error")
+	}
+}
+
+// TestDecode tests results are decoded through json
+func TestDecode(t *testing.T) {
+	vm, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	var out struct {
+		Name  string   `json:"name"`
+		Tags  []string `json:"tags"`
+		Count int      `json:"count"`
+	}
+	expr := "Promise.resolve({ name: 'godzilla', tags: ['a', 'b'], count: 2 })"
+	if err := vm.Decode(context.Background(), "decode.js", expr, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "godzilla" || len(out.Tags) != 2 || out.Count != 2 {
+		t.Fatalf("decoded %+v", out)
+	}
+
+	var value interface{} = "untouched"
+	if err := vm.Decode(context.Background(), "undefined.js", "undefined", &value); err != nil || value != "untouched" {
+		t.Fatalf("decoded undefined into %v, %v", value, err)
+	}
+}
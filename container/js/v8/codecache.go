@@ -0,0 +1,159 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/codecache.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"rogchap.com/v8go"
+)
+
+// CodeCache keeps the v8 code cache of every compiled script in memory and,
+// when it has a directory, on disk so it survives restarts. An entry belongs
+// to a script path and is only used while the script source hash matches, so
+// changed sources invalidate and replace their entry.
+type CodeCache struct {
+	dir     string
+	mutex   sync.RWMutex
+	entries map[string]*codeCacheEntry
+}
+
+type codeCacheEntry struct {
+	sum  [sha256.Size]byte
+	data []byte
+}
+
+// NewCodeCache returns a code cache persisted in dir, or kept in memory only
+// when dir is empty
+func NewCodeCache(dir string) (*CodeCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &CodeCache{
+		dir:     dir,
+		entries: make(map[string]*codeCacheEntry),
+	}, nil
+}
+
+// get returns the cached data of the script at path with source hash sum
+func (c *CodeCache) get(path string, sum [sha256.Size]byte) []byte {
+	c.mutex.RLock()
+	entry, ok := c.entries[path]
+	c.mutex.RUnlock()
+
+	if ok && entry.sum == sum && len(entry.data) > 0 {
+		return entry.data
+	}
+
+	if c.dir == "" {
+		return nil
+	}
+
+	raw, err := os.ReadFile(c.file(path))
+	if err != nil || len(raw) <= sha256.Size || !bytes.Equal(raw[:sha256.Size], sum[:]) {
+		return nil
+	}
+
+	entry = &codeCacheEntry{sum: sum, data: raw[sha256.Size:]}
+	c.mutex.Lock()
+	c.entries[path] = entry
+	c.mutex.Unlock()
+	return entry.data
+}
+
+// put stores data as the cached data of the script at path with source hash sum
+func (c *CodeCache) put(path string, sum [sha256.Size]byte, data []byte) {
+	c.mutex.Lock()
+	c.entries[path] = &codeCacheEntry{sum: sum, data: data}
+	c.mutex.Unlock()
+
+	if c.dir == "" {
+		return
+	}
+
+	// write to a temporary file first so concurrent readers never see half a file
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(append(sum[:], data...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), c.file(path)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// file returns the file caching the script at path, v8 rejects data of other
+// versions so the version is part of the name
+func (c *CodeCache) file(path string) string {
+	key := sha256.Sum256([]byte(v8go.Version() + "\x00" + path))
+	return filepath.Join(c.dir, hex.EncodeToString(key[:16])+".v8cache")
+}
+
+// compile compiles code with the cached data of cache and returns a function
+// refreshing the cached data after the first run, it is nil unless the data
+// was missing or rejected
+func (vm *VM) compile(path, code string) (*v8go.UnboundScript, func(), error) {
+	cache := vm.settings.CodeCache
+	if cache == nil {
+		script, err := vm.isolate.CompileUnboundScript(code, path, v8go.CompileOptions{})
+		return script, nil, err
+	}
+
+	sum := sha256.Sum256([]byte(code))
+	options := v8go.CompileOptions{}
+	// v8go reads the first byte of the cached data, empty data is never passed
+	if data := cache.get(path, sum); len(data) > 0 {
+		options.CachedData = &v8go.CompilerCachedData{Bytes: data}
+	}
+
+	script, err := vm.isolate.CompileUnboundScript(code, path, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if options.CachedData != nil && !options.CachedData.Rejected {
+		return script, nil, nil
+	}
+
+	// functions compiled lazily while running are included in the cache
+	return script, func() {
+		if data := script.CreateCodeCache(); data != nil && len(data.Bytes) > 0 {
+			cache.put(path, sum, data.Bytes)
+		}
+	}, nil
+}
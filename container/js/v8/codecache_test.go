@@ -0,0 +1,80 @@
+package v8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"testing"
+)
+
+const cachedScript = `function fib(n) { return n < 2 ? n : fib(n - 1) + fib(n - 2); } fib(10);`
+
+// TestCodeCache tests compiled scripts are cached on disk and used by new vms
+func TestCodeCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCodeCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(cache *CodeCache) bool {
+		t.Helper()
+		vm, err := Load(&Settings{CodeCache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer vm.Close()
+
+		script, refresh, err := vm.compile("fib.js", cachedScript)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := script.Run(vm.context); err != nil {
+			t.Fatal(err)
+		}
+		if refresh != nil {
+			refresh()
+		}
+		return refresh == nil
+	}
+
+	if compile(cache) {
+		t.Fatal("first compile hit an empty cache")
+	}
+
+	// a new cache reads the entry back from disk
+	reloaded, _ := NewCodeCache(dir)
+	if !compile(reloaded) {
+		t.Fatal("compile missed the cached data on disk")
+	}
+
+	file := cache.file("fib.js")
+	sum := sha256.Sum256([]byte(cachedScript))
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "corrupt", data: append(sum[:], []byte("not v8 cached data")...)},
+		{name: "empty", data: sum[:]},
+		{name: "truncated", data: sum[:8]},
+		{name: "stale", data: append(make([]byte, sha256.Size), 1, 2, 3)},
+	}
+
+	for _, tc := range testCases {
+		if err := os.WriteFile(file, tc.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fresh, _ := NewCodeCache(dir)
+		if compile(fresh) {
+			t.Fatalf("%s: compile used the cache file", tc.name)
+		}
+
+		// the unusable file is replaced with fresh cached data
+		raw, err := os.ReadFile(file)
+		if err != nil || len(raw) <= sha256.Size || !bytes.Equal(raw[:sha256.Size], sum[:]) {
+			t.Fatalf("%s: cache file was not refreshed", tc.name)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/console.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godzillaframework/godzilla/container/js"
+	"rogchap.com/v8go"
+)
+
+// Console levels
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// consoleMethods maps the console methods to their level
+var consoleMethods = map[string]string{
+	"debug": LevelDebug,
+	"log":   LevelInfo,
+	"info":  LevelInfo,
+	"table": LevelInfo,
+	"warn":  LevelWarn,
+	"error": LevelError,
+}
+
+// ConsoleEntry is a message logged by a script through console
+type ConsoleEntry struct {
+	Time      time.Time
+	Level     string // debug, info, warn or error
+	Method    string // console method, e.g. "log" or "table"
+	Message   string
+	Path      string // path of the script being evaluated
+	RequestID string // id of the request being handled, see js.WithRequestID
+}
+
+// ConsoleSink receives the console messages of scripts, ctx is the context
+// the vm is evaluating with
+type ConsoleSink interface {
+	Console(ctx context.Context, entry *ConsoleEntry)
+}
+
+// ConsoleFunc adapts a function to a ConsoleSink
+type ConsoleFunc func(ctx context.Context, entry *ConsoleEntry)
+
+func (fn ConsoleFunc) Console(ctx context.Context, entry *ConsoleEntry) {
+	fn(ctx, entry)
+}
+
+// StdConsole writes warnings and errors to stderr and everything else to
+// stdout, messages are prefixed with the request id when there is one
+var StdConsole ConsoleSink = ConsoleFunc(func(ctx context.Context, entry *ConsoleEntry) {
+	var out io.Writer = os.Stdout
+	if entry.Level == LevelWarn || entry.Level == LevelError {
+		out = os.Stderr
+	}
+
+	if entry.RequestID != "" {
+		fmt.Fprintf(out, "[%s] %s\n", entry.RequestID, entry.Message)
+		return
+	}
+	fmt.Fprintln(out, entry.Message)
+})
+
+// ConsoleBuffer collects console entries, e.g. to assert on them in tests
+type ConsoleBuffer struct {
+	mutex   sync.Mutex
+	entries []ConsoleEntry
+}
+
+// Entries returns a copy of the collected entries
+func (b *ConsoleBuffer) Entries() []ConsoleEntry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]ConsoleEntry(nil), b.entries...)
+}
+
+// String returns the collected messages, one per line
+func (b *ConsoleBuffer) String() string {
+	var sb strings.Builder
+	for _, entry := range b.Entries() {
+		sb.WriteString(entry.Message)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func (b *ConsoleBuffer) Console(ctx context.Context, entry *ConsoleEntry) {
+	b.mutex.Lock()
+	b.entries = append(b.entries, *entry)
+	b.mutex.Unlock()
+}
+
+type consoleBufferKey struct{}
+
+// WithConsoleBuffer returns a context capturing the console entries of every
+// evaluation running with it, in addition to the vm console sink
+func WithConsoleBuffer(ctx context.Context) (context.Context, *ConsoleBuffer) {
+	buf := &ConsoleBuffer{}
+	return context.WithValue(ctx, consoleBufferKey{}, buf), buf
+}
+
+// injectConsole replaces the console object of the context, which v8 only
+// wires to an inspector
+func (vm *VM) injectConsole() error {
+	console := v8go.NewObjectTemplate(vm.isolate)
+
+	for method, level := range consoleMethods {
+		method, level := method, level
+		fn := v8go.NewFunctionTemplate(vm.isolate, func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+			var message string
+			if method == "table" {
+				message = vm.formatTable(info.Context(), info.Args())
+			} else {
+				message = vm.formatArgs(info.Context(), info.Args())
+			}
+			vm.log(method, level, message)
+			return nil
+		})
+
+		if err := console.Set(method, fn); err != nil {
+			return err
+		}
+	}
+	object, err := console.NewInstance(vm.context)
+	if err != nil {
+		return err
+	}
+	return vm.context.Global().Set("console", object)
+}
+
+// log sends a console message to the sink and the buffer of the evaluation
+func (vm *VM) log(method, level, message string) {
+	ctx := vm.evalCtx()
+
+	entry := &ConsoleEntry{
+		Time:      time.Now(),
+		Level:     level,
+		Method:    method,
+		Message:   message,
+		Path:      vm.path,
+		RequestID: js.RequestIDFrom(ctx),
+	}
+
+	if buf, ok := ctx.Value(consoleBufferKey{}).(*ConsoleBuffer); ok {
+		buf.Console(ctx, entry)
+	}
+
+	sink := vm.settings.Console
+	if sink == nil {
+		sink = StdConsole
+	}
+	sink.Console(ctx, entry)
+}
+
+// formatArgs joins the console arguments like browsers do
+func (vm *VM) formatArgs(ctx *v8go.Context, args []*v8go.Value) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = vm.formatValue(ctx, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatValue formats errors with their stack, objects as json and everything
+// else as its string
+func (vm *VM) formatValue(ctx *v8go.Context, value *v8go.Value) string {
+	if value.IsNativeError() {
+		if stack, err := value.Object().Get("stack"); err == nil && stack.IsString() {
+			if vm.loader != nil {
+				return vm.loader.rewriteLocations(stack.String())
+			}
+			return stack.String()
+		}
+	}
+
+	if value.IsObject() && !value.IsFunction() {
+		if raw, err := v8go.JSONStringify(ctx, value); err == nil {
+			return raw
+		}
+	}
+	return value.String()
+}
+
+// formatTable renders the first argument of console.table as a text table
+func (vm *VM) formatTable(ctx *v8go.Context, args []*v8go.Value) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	raw, err := v8go.JSONStringify(ctx, args[0])
+	if err != nil {
+		return vm.formatArgs(ctx, args)
+	}
+
+	rows := make(map[string]interface{})
+	var list []interface{}
+	if json.Unmarshal([]byte(raw), &list) == nil {
+		for i, row := range list {
+			rows[fmt.Sprint(i)] = row
+		}
+	} else if json.Unmarshal([]byte(raw), &rows) != nil {
+		return vm.formatArgs(ctx, args)
+	}
+
+	// collect index and column names in a stable order
+	var index, columns []string
+	seen := make(map[string]bool)
+	for key, row := range rows {
+		index = append(index, key)
+		if fields, ok := row.(map[string]interface{}); ok {
+			for column := range fields {
+				if !seen[column] {
+					seen[column] = true
+					columns = append(columns, column)
+				}
+			}
+		} else if !seen["Values"] {
+			seen["Values"] = true
+			columns = append(columns, "Values")
+		}
+	}
+	sortIndex(index)
+	sort.Strings(columns)
+
+	table := [][]string{append([]string{"(index)"}, columns...)}
+	for _, key := range index {
+		line := []string{key}
+		fields, isObject := rows[key].(map[string]interface{})
+		for _, column := range columns {
+			var cell interface{}
+			if isObject {
+				cell = fields[column]
+			} else if column == "Values" {
+				cell = rows[key]
+			}
+
+			if cell == nil {
+				line = append(line, "")
+			} else if s, ok := cell.(string); ok {
+				line = append(line, s)
+			} else {
+				cellRaw, _ := json.Marshal(cell)
+				line = append(line, string(cellRaw))
+			}
+		}
+		table = append(table, line)
+	}
+	return renderTable(table)
+}
+
+// sortIndex sorts numeric indexes numerically and everything else by name
+func sortIndex(index []string) {
+	sort.Slice(index, func(i, j int) bool {
+		if len(index[i]) != len(index[j]) && isDigits(index[i]) && isDigits(index[j]) {
+			return len(index[i]) < len(index[j])
+		}
+		return index[i] < index[j]
+	})
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// renderTable draws rows with the first row as header
+func renderTable(rows [][]string) string {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := len([]rune(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var sb strings.Builder
+	separator := func() {
+		for _, width := range widths {
+			sb.WriteString("+" + strings.Repeat("-", width+2))
+		}
+		sb.WriteString("+\n")
+	}
+
+	separator()
+	for i, row := range rows {
+		for j, cell := range row {
+			sb.WriteString("| " + cell + strings.Repeat(" ", widths[j]-len([]rune(cell))) + " ")
+		}
+		sb.WriteString("|\n")
+		if i == 0 {
+			separator()
+		}
+	}
+	separator()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
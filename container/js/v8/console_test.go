@@ -0,0 +1,114 @@
+package v8
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/godzillaframework/godzilla/container/js"
+)
+
+// TestConsole tests console methods are routed to their level with the
+// path and request id of the evaluation
+func TestConsole(t *testing.T) {
+	sink := &ConsoleBuffer{}
+	vm, err := Load(&Settings{Console: sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	ctx, buf := WithConsoleBuffer(js.WithRequestID(context.Background(), "req-1"))
+	expr := `console.debug("debug", 1);
+console.log("log", { a: 1 });
+console.info("info");
+console.warn("warn", [1, 2]);
+console.error(new Error("boom"));
+console.table([{ a: 1 }]);`
+	if _, err := vm.EvalContext(ctx, "console.js", expr); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		method  string
+		level   string
+		message string
+	}{
+		{method: "debug", level: LevelDebug, message: "debug 1"},
+		{method: "log", level: LevelInfo, message: `log {"a":1}`},
+		{method: "info", level: LevelInfo, message: "info"},
+		{method: "warn", level: LevelWarn, message: "warn [1,2]"},
+		{method: "error", level: LevelError, message: "Error: boom\n    at console.js:5"},
+		{method: "table", level: LevelInfo, message: "+---------+---+\n| (index) | a |"},
+	}
+
+	for _, entries := range [][]ConsoleEntry{sink.Entries(), buf.Entries()} {
+		if len(entries) != len(testCases) {
+			t.Fatalf("logged %d entries expected %d", len(entries), len(testCases))
+		}
+		for i, tc := range testCases {
+			entry := entries[i]
+			if entry.Method != tc.method || entry.Level != tc.level || !strings.HasPrefix(entry.Message, tc.message) {
+				t.Fatalf("%s: logged %s %s %q expected %s %q", tc.method, entry.Method, entry.Level, entry.Message, tc.level, tc.message)
+			}
+			if entry.Path != "console.js" || entry.RequestID != "req-1" {
+				t.Fatalf("%s: logged path %q request id %q", tc.method, entry.Path, entry.RequestID)
+			}
+		}
+	}
+
+	// the buffer only captures evaluations running with its context
+	if _, err := vm.Eval("other.js", `console.log("other")`); err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Entries()) != len(testCases) || len(sink.Entries()) != len(testCases)+1 {
+		t.Fatal("buffer captured an evaluation of another context")
+	}
+}
+
+// TestStdConsole tests warnings and errors go to stderr and the rest to stdout
+func TestStdConsole(t *testing.T) {
+	capture := func(out **os.File, fn func()) string {
+		t.Helper()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *out
+		*out = w
+		fn()
+		*out = saved
+		w.Close()
+
+		raw, _ := io.ReadAll(r)
+		return string(raw)
+	}
+
+	log := func(level, requestID string) func() {
+		return func() {
+			StdConsole.Console(context.Background(), &ConsoleEntry{Level: level, Message: level, RequestID: requestID})
+		}
+	}
+
+	testCases := []struct {
+		level string
+		out   **os.File
+	}{
+		{level: LevelDebug, out: &os.Stdout},
+		{level: LevelInfo, out: &os.Stdout},
+		{level: LevelWarn, out: &os.Stderr},
+		{level: LevelError, out: &os.Stderr},
+	}
+
+	for _, tc := range testCases {
+		if written := capture(tc.out, log(tc.level, "")); written != tc.level+"\n" {
+			t.Fatalf("%s: wrote %q", tc.level, written)
+		}
+	}
+
+	if written := capture(&os.Stdout, log(LevelInfo, "req-1")); written != "[req-1] info\n" {
+		t.Fatalf("wrote %q expected the request id prefix", written)
+	}
+}
@@ -0,0 +1,1041 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/esm.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// esm rewrites the imports and exports of an es module into a module
+// definition. Imports are hoisted into the module prelude and every read of
+// an imported name is rewritten to read the exporting module, which keeps
+// the bindings live and lets cyclic imports see each other's exports.
+type esm struct {
+	loader  *Loader
+	m       *module
+	src     string
+	tokens  []token
+	match   []int  // index of the bracket closing every opening token
+	skip    []bool // tokens of removed import and export statements
+	edits   []edit
+	exports []binding
+	imports []string          // hoisted imports and re-exports
+	locals  map[string]string // imported names and the expressions reading them
+	scopes  []scope           // scopes binding imported names, the module scope first
+	seq     int
+}
+
+// binding is an item of an import or export list, "name as alias"
+type binding struct {
+	name  string
+	alias string
+}
+
+// edit replaces src[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// anchor pins a generated column to a column of the source, the columns
+// after it keep their distance to the anchor unless it is fixed
+type anchor struct {
+	gen, orig int
+	fixed     bool
+}
+
+// position is a zero based line and utf-16 column as v8 reports them
+type position struct {
+	line, column int
+}
+
+func (p *position) advance(s string) {
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			p.line++
+			p.column = 0
+		case r >= 0x10000:
+			p.column += 2
+		default:
+			p.column++
+		}
+	}
+}
+
+type frameKind int
+
+const (
+	frameBlock frameKind = iota
+	frameObject
+	frameClass
+	frameParen
+	frameBracket
+	frameTemplate
+)
+
+// frame is an open bracket of the source
+type frame struct {
+	kind       frameKind
+	conditions int // conditional operators waiting for their colon
+	scope      int // index of the scope the bracket opens, -1 for none
+}
+
+// read is a name read in the open bracket top
+type read struct {
+	at  int
+	top frame
+}
+
+// scope is a block or function scope spanning the tokens from start to end
+type scope struct {
+	start, end int
+	function   bool           // var declarations are hoisted to it
+	names      map[string]int // imported names it binds and their declarations
+}
+
+// noToken stands in for the tokens before the first and after the last one
+var noToken = &token{kind: tokenPunct}
+
+// transform rewrites the imports and exports of source into a module definition
+func (l *Loader) transform(p, source string) (*module, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("v8: %s:%v", p, err)
+	}
+
+	e := &esm{
+		loader: l,
+		m:      &module{path: p},
+		src:    source,
+		tokens: tokens,
+		match:  matchBrackets(tokens),
+		skip:   make([]bool, len(tokens)),
+		locals: make(map[string]string),
+	}
+
+	if err := e.statements(); err != nil {
+		return nil, err
+	}
+	if err := e.references(); err != nil {
+		return nil, err
+	}
+
+	// exports are defined first so modules importing this one back see them
+	var prelude []string
+	for _, b := range e.exports {
+		local := b.name
+		if ref, ok := e.locals[local]; ok {
+			local = ref
+		}
+		prelude = append(prelude, fmt.Sprintf(
+			"Object.defineProperty(__exports, %s, { enumerable: true, get: function () { return %s; } });",
+			jsString(b.alias), local))
+	}
+	prelude = append(prelude, e.imports...)
+
+	e.m.code = e.apply("__godzilla_define("+jsString(p)+", function (__import, __exports, module, exports) { "+
+		strings.Join(prelude, " ")+" ") + "\n});"
+	return e.m, nil
+}
+
+func (e *esm) errorf(i int, format string, args ...interface{}) error {
+	return fmt.Errorf("v8: %s:%d: "+format, append([]interface{}{e.m.path, e.at(i).line + 1}, args...)...)
+}
+
+// at returns the token at i or noToken when i is out of range
+func (e *esm) at(i int) *token {
+	if i < 0 || i >= len(e.tokens) {
+		return noToken
+	}
+	return &e.tokens[i]
+}
+
+// member reports whether the token at i is a property name after a dot
+func (e *esm) member(i int) bool {
+	return e.at(i-1).is(".") || e.at(i-1).is("?.")
+}
+
+// name returns the name or string at i of an import or export list
+func (e *esm) name(i int) (string, bool) {
+	t := e.at(i)
+	switch t.kind {
+	case tokenName:
+		return t.text, t.text != ""
+	case tokenString:
+		s, err := unquote(t.text)
+		return s, err == nil
+	}
+	return "", false
+}
+
+// statements rewrites the import and export statements of the module
+func (e *esm) statements() error {
+	depth := 0
+	for i := 0; i < len(e.tokens); {
+		t := &e.tokens[i]
+		if depth == 0 && !e.member(i) {
+			var end int
+			var err error
+			switch {
+			case t.is("import") && !e.at(i+1).is("(") && !e.at(i+1).is("."):
+				end, err = e.importDecl(i)
+			case t.is("export"):
+				end, err = e.exportDecl(i)
+			}
+			if err != nil {
+				return err
+			}
+			if end > 0 {
+				i = end
+				continue
+			}
+		}
+
+		if closer(t) {
+			depth--
+		}
+		if opener(t) {
+			depth++
+		}
+		i++
+	}
+	return nil
+}
+
+// importDecl hoists the import statement at i and returns the index after it
+func (e *esm) importDecl(i int) (int, error) {
+	start := i
+	var def, ns string
+	var named []binding
+
+	i++
+	if e.at(i).kind != tokenString {
+		if e.at(i).kind == tokenName && (e.at(i+1).is(",") || e.at(i+1).is("from")) {
+			def = e.tokens[i].text
+			i++
+			if e.at(i).is(",") {
+				i++
+			}
+		}
+
+		switch {
+		case e.at(i).is("*") && e.at(i+1).is("as") && e.at(i+2).kind == tokenName:
+			ns = e.tokens[i+2].text
+			i += 3
+		case e.at(i).is("{"):
+			var err error
+			if named, i, err = e.list(start, i); err != nil {
+				return 0, err
+			}
+		}
+
+		if !e.at(i).is("from") {
+			return 0, e.errorf(start, "unsupported import")
+		}
+		i++
+	}
+
+	resolved, end, err := e.specifier(start, i)
+	if err != nil {
+		return 0, err
+	}
+	e.remove(start, end)
+
+	if def == "" && ns == "" && len(named) == 0 {
+		e.imports = append(e.imports, "__import("+jsString(resolved)+");")
+		return end, nil
+	}
+
+	e.seq++
+	module := fmt.Sprintf("__module%d", e.seq)
+	e.imports = append(e.imports, "const "+module+" = __import("+jsString(resolved)+");")
+
+	if ns != "" {
+		e.imports = append(e.imports, "const "+ns+" = "+module+";")
+	}
+	if def != "" {
+		e.locals[def] = property(module, "default")
+	}
+	for _, b := range named {
+		e.locals[b.alias] = property(module, b.name)
+	}
+	return end, nil
+}
+
+// exportDecl rewrites the export statement at i and returns the index the
+// statement continues at
+func (e *esm) exportDecl(i int) (int, error) {
+	start := i
+	next := e.at(i + 1)
+
+	switch {
+	case next.is("*"):
+		i += 2
+		alias := ""
+		if e.at(i).is("as") {
+			var ok bool
+			if alias, ok = e.name(i + 1); !ok {
+				return 0, e.errorf(start, "unsupported export")
+			}
+			i += 2
+		}
+		if !e.at(i).is("from") {
+			return 0, e.errorf(start, "unsupported export")
+		}
+
+		resolved, end, err := e.specifier(start, i+1)
+		if err != nil {
+			return 0, err
+		}
+		source := "__import(" + jsString(resolved) + ")"
+		if alias != "" {
+			source = "{ " + jsString(alias) + ": " + source + " }"
+		}
+		e.imports = append(e.imports, "__godzilla_reexport(__exports, "+source+");")
+		e.remove(start, end)
+		return end, nil
+
+	case next.is("{"):
+		items, end, err := e.list(start, i+1)
+		if err != nil {
+			return 0, err
+		}
+
+		if e.at(end).is("from") {
+			resolved, fromEnd, err := e.specifier(start, end+1)
+			if err != nil {
+				return 0, err
+			}
+			names := make(map[string]string)
+			for _, b := range items {
+				names[b.alias] = b.name
+			}
+			e.imports = append(e.imports, "__godzilla_reexport(__exports, __import("+jsString(resolved)+"), "+jsObject(names)+");")
+			e.remove(start, fromEnd)
+			return fromEnd, nil
+		}
+
+		if e.at(end).is(";") {
+			end++
+		}
+		e.exports = append(e.exports, items...)
+		e.remove(start, end)
+		return end, nil
+
+	case next.is("default"):
+		i += 2
+		decl := i
+		if e.at(i).is("async") && e.at(i+1).is("function") && !e.at(i+1).newline {
+			decl++
+		}
+
+		if !e.at(decl).is("function") && !e.at(decl).is("class") {
+			e.replace(start, i, "__exports.default =")
+			return i, nil
+		}
+
+		name := decl + 1
+		if e.at(name).is("*") {
+			name++
+		}
+		e.remove(start, i)
+
+		if e.at(name).kind == tokenName && !e.at(name).is("extends") {
+			e.exports = append(e.exports, binding{name: e.tokens[name].text, alias: "default"})
+			return i, nil
+		}
+
+		// anonymous declarations are named so they are hoisted like named ones
+		pos := e.tokens[name-1].end
+		e.edits = append(e.edits, edit{start: pos, end: pos, text: " __default"})
+		e.exports = append(e.exports, binding{name: "__default", alias: "default"})
+		return i, nil
+
+	case next.is("var") || next.is("let") || next.is("const"):
+		for _, name := range e.declarators(i + 2) {
+			text := e.tokens[name].text
+			e.exports = append(e.exports, binding{name: text, alias: text})
+		}
+		e.remove(start, start+1)
+		return i + 1, nil
+
+	case next.is("function") || next.is("class") || next.is("async") && e.at(i+2).is("function"):
+		name := i + 2
+		if next.is("async") {
+			name++
+		}
+		if e.at(name).is("*") {
+			name++
+		}
+		if e.at(name).kind != tokenName || e.at(name).is("extends") {
+			return 0, e.errorf(start, "exported declarations need a name")
+		}
+		e.exports = append(e.exports, binding{name: e.tokens[name].text, alias: e.tokens[name].text})
+		e.remove(start, start+1)
+		return i + 1, nil
+	}
+
+	return 0, e.errorf(start, "unsupported export")
+}
+
+// list parses the braced import or export list at i of the statement at
+// start and returns the index after it
+func (e *esm) list(start, i int) ([]binding, int, error) {
+	var items []binding
+
+	for i++; !e.at(i).is("}"); i++ {
+		name, ok := e.name(i)
+		if !ok {
+			return nil, 0, e.errorf(start, "unsupported %s list", e.tokens[start].text)
+		}
+		item := binding{name: name, alias: name}
+
+		if e.at(i + 1).is("as") {
+			if item.alias, ok = e.name(i + 2); !ok {
+				return nil, 0, e.errorf(start, "unsupported %s list", e.tokens[start].text)
+			}
+			i += 2
+		}
+		items = append(items, item)
+
+		if !e.at(i + 1).is(",") {
+			i++
+			break
+		}
+		i++
+	}
+
+	if !e.at(i).is("}") {
+		return nil, 0, e.errorf(start, "unsupported %s list", e.tokens[start].text)
+	}
+	return items, i + 1, nil
+}
+
+// specifier resolves the module specifier at i of the statement at start and
+// returns the index after the statement
+func (e *esm) specifier(start, i int) (string, int, error) {
+	if e.at(i).kind != tokenString {
+		return "", 0, e.errorf(start, "unsupported %s", e.tokens[start].text)
+	}
+	spec, err := unquote(e.tokens[i].text)
+	if err != nil {
+		return "", 0, e.errorf(i, "invalid module specifier %s", e.tokens[i].text)
+	}
+
+	i++
+	if (e.at(i).is("assert") || e.at(i).is("with")) && !e.at(i).newline {
+		return "", 0, e.errorf(i, "import attributes are not supported")
+	}
+	if e.at(i).is(";") {
+		i++
+	}
+
+	resolved, err := e.loader.resolve(e.m.path, spec)
+	if err != nil {
+		return "", 0, err
+	}
+	for _, dep := range e.m.deps {
+		if dep == resolved {
+			return resolved, i, nil
+		}
+	}
+	e.m.deps = append(e.m.deps, resolved)
+	return resolved, i, nil
+}
+
+// remove blanks the tokens from start to end, keeping the line breaks
+func (e *esm) remove(start, end int) {
+	s := e.src[e.tokens[start].start:e.tokens[end-1].end]
+	e.replace(start, end, strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+		return ' '
+	}, s))
+}
+
+// replace replaces the tokens from start to end with text
+func (e *esm) replace(start, end int, text string) {
+	e.edits = append(e.edits, edit{start: e.tokens[start].start, end: e.tokens[end-1].end, text: text})
+	for i := start; i < end; i++ {
+		e.skip[i] = true
+	}
+}
+
+// references rewrites the reads of imported names, the brackets of the
+// source are tracked to tell property names, labels and shorthand properties
+// from references. Names bound in an inner scope are left alone, the scopes
+// are collected first since declarations are hoisted to the top of theirs.
+func (e *esm) references() error {
+	if len(e.locals) == 0 {
+		return nil
+	}
+
+	e.scopes = []scope{{end: len(e.tokens), function: true}}
+	stack := []frame{{kind: frameBlock}}
+	bodies := make(map[int]int) // scopes of function bodies and loop heads by their opening bracket
+	class := -1                 // stack depth of the next class body
+	conditional := false
+	var reads []read
+
+	for i := range e.tokens {
+		t := &e.tokens[i]
+		if closer(t) && len(stack) > 1 {
+			stack = stack[:len(stack)-1]
+		}
+		top := &stack[len(stack)-1]
+		colon := false
+
+		switch {
+		case e.skip[i] || e.member(i):
+		case t.kind == tokenName:
+			next := e.at(i + 1)
+			switch {
+			case (t.is("var") || t.is("let") || t.is("const")) && (next.kind == tokenName || next.is("{") || next.is("[")):
+				e.bind(e.enclosing(stack, t.is("var")), e.declarators(i+1))
+			case t.is("function"):
+				j := i + 1
+				if e.at(j).is("*") {
+					j++
+				}
+				name := -1
+				if e.at(j).kind == tokenName {
+					name = j
+					j++
+				}
+				if e.at(j).is("(") && e.match[j] > 0 {
+					s := e.function(i, j, bodies)
+					if name >= 0 {
+						if e.declaration(i, top) {
+							s = e.enclosing(stack, false)
+						}
+						e.bind(s, []int{name})
+					}
+				}
+			case t.is("class") && (next.kind == tokenName || next.is("{")):
+				class = len(stack)
+				if next.kind == tokenName && !next.is("extends") {
+					s := e.enclosing(stack, false)
+					if !e.declaration(i, top) {
+						s = e.scope(i, e.classEnd(i), false)
+					}
+					e.bind(s, []int{i + 1})
+				}
+			case t.is("catch") && next.is("(") && e.match[i+1] > 0:
+				names, _ := e.pattern(i + 2)
+				e.bind(e.scope(i+1, e.bodyEnd(e.match[i+1]+1), false), names)
+			case t.is("for"):
+				p := i + 1
+				if e.at(p).is("await") {
+					p++
+				}
+				if e.at(p).is("(") && e.match[p] > 0 {
+					bodies[p] = e.scope(p, e.bodyEnd(e.match[p]+1), false)
+				}
+			case next.is("=>"):
+				s := e.scope(i, e.bodyEnd(i+2), true)
+				if e.at(i + 2).is("{") {
+					bodies[i+2] = s
+				}
+				e.bind(s, []int{i})
+			default:
+				reads = append(reads, read{at: i, top: *top})
+			}
+		case t.is("?"):
+			top.conditions++
+		case t.is(":") && top.conditions > 0:
+			top.conditions--
+			colon = true
+		case t.is("(") && e.match[i] > 0:
+			after := e.at(e.match[i] + 1)
+			_, known := bodies[e.match[i]+1]
+			if !known && (after.is("=>") || after.is("{") && (top.kind == frameObject || top.kind == frameClass)) {
+				e.function(i, i, bodies)
+			}
+		}
+
+		if opener(t) {
+			f := frame{kind: frameParen, scope: -1}
+			switch {
+			case t.kind == tokenTemplate:
+				f.kind = frameTemplate
+			case t.is("["):
+				f.kind = frameBracket
+			case t.is("{") && class == len(stack):
+				f.kind = frameClass
+				class = -1
+			case t.is("{"):
+				f.kind = e.brace(i, top, conditional)
+			}
+			if s, ok := bodies[i]; ok {
+				f.scope = s
+			} else if f.kind == frameBlock {
+				f.scope = e.scope(i, e.bodyEnd(i), false)
+			}
+			stack = append(stack, f)
+		}
+		conditional = colon
+	}
+
+	// a top level declaration of an imported name is a redeclaration
+	first := -1
+	for _, i := range e.scopes[0].names {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	if first >= 0 {
+		return e.errorf(first, "%q redeclares an imported binding", e.tokens[first].text)
+	}
+
+	for _, r := range reads {
+		if !e.shadowed(r.at) {
+			e.reference(r.at, &r.top)
+		}
+	}
+	return nil
+}
+
+// scope records a scope spanning the tokens from start to end
+func (e *esm) scope(start, end int, function bool) int {
+	e.scopes = append(e.scopes, scope{start: start, end: end, function: function})
+	return len(e.scopes) - 1
+}
+
+// enclosing returns the innermost scope of the open brackets, the innermost
+// function scope for var declarations
+func (e *esm) enclosing(stack []frame, function bool) int {
+	for i := len(stack) - 1; i > 0; i-- {
+		if s := stack[i].scope; s >= 0 && (!function || e.scopes[s].function) {
+			return s
+		}
+	}
+	return 0
+}
+
+// bind declares the imported names among the names at the given indexes in s
+func (e *esm) bind(s int, names []int) {
+	for _, i := range names {
+		name := e.tokens[i].text
+		if _, ok := e.locals[name]; !ok {
+			continue
+		}
+		if e.scopes[s].names == nil {
+			e.scopes[s].names = make(map[string]int)
+		}
+		if _, ok := e.scopes[s].names[name]; !ok {
+			e.scopes[s].names[name] = i
+		}
+	}
+}
+
+// shadowed reports whether the name at i is bound by a scope enclosing it
+func (e *esm) shadowed(i int) bool {
+	name := e.tokens[i].text
+	for _, s := range e.scopes[1:] {
+		if _, ok := s.names[name]; ok && s.start <= i && i < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// function records the scope of the function starting at start whose
+// parameters open at p and binds its parameters
+func (e *esm) function(start, p int, bodies map[int]int) int {
+	body := e.match[p] + 1
+	if e.at(body).is("=>") {
+		body++
+	}
+	s := e.scope(start, e.bodyEnd(body), true)
+	if e.at(body).is("{") {
+		bodies[body] = s
+	}
+	e.bind(s, e.params(p))
+	return s
+}
+
+// declaration reports whether the function or class at i starts a statement
+// and so declares its name in the enclosing scope
+func (e *esm) declaration(i int, top *frame) bool {
+	if e.at(i - 1).is("async") {
+		i--
+	}
+	if top.kind != frameBlock {
+		return false
+	}
+	prev, t := e.at(i-1), e.at(i)
+	return prev == noToken || e.skip[i-1] || prev.is(";") || prev.is("{") || prev.is("}") ||
+		t.newline && !continues(prev, t)
+}
+
+// bodyEnd returns the index after the block or expression at i
+func (e *esm) bodyEnd(i int) int {
+	if !e.at(i).is("{") {
+		return e.expressionEnd(i)
+	}
+	if e.match[i] < 0 {
+		return len(e.tokens)
+	}
+	return e.match[i] + 1
+}
+
+// classEnd returns the index after the body of the class at i
+func (e *esm) classEnd(i int) int {
+	for j := i + 1; j < len(e.tokens); j++ {
+		if e.at(j).is("{") {
+			return e.bodyEnd(j)
+		}
+		if opener(&e.tokens[j]) && e.match[j] > 0 {
+			j = e.match[j]
+		}
+	}
+	return len(e.tokens)
+}
+
+// reference rewrites the name at i when it reads an imported binding
+func (e *esm) reference(i int, top *frame) {
+	t := &e.tokens[i]
+	ref, ok := e.locals[t.text]
+	if !ok {
+		return
+	}
+	prev, next := e.at(i-1), e.at(i+1)
+
+	switch top.kind {
+	case frameObject:
+		if prev.is("{") || prev.is(",") {
+			switch {
+			case next.is(":") || next.is("("):
+				return
+			case next.is(",") || next.is("}") || next.is("="):
+				e.replace(i, i+1, t.text+": "+ref)
+				return
+			}
+		}
+		if next.is("(") && (prev.is("get") || prev.is("set") || prev.is("async") || prev.is("*")) {
+			return
+		}
+	case frameClass:
+		if t.newline || prev.is("{") || prev.is(";") || prev.is("}") || prev.is("static") ||
+			prev.is("get") || prev.is("set") || prev.is("async") || prev.is("*") {
+			return
+		}
+	case frameBlock:
+		// labels
+		if next.is(":") && top.conditions == 0 && !prev.is("case") {
+			return
+		}
+	}
+
+	if prev.is("break") || prev.is("continue") {
+		return
+	}
+	if prev.is("new") && strings.HasSuffix(ref, ")") {
+		ref = "(" + ref + ")"
+	}
+	e.replace(i, i+1, ref)
+}
+
+// brace tells object literals from blocks by the token before the brace at i
+func (e *esm) brace(i int, top *frame, conditional bool) frameKind {
+	prev := e.at(i - 1)
+	switch prev.kind {
+	case tokenPunct:
+		switch prev.text {
+		case "", ")", ";", "{", "}", "=>":
+			return frameBlock
+		case ":":
+			if top.kind == frameBlock && !conditional {
+				return frameBlock
+			}
+		}
+		return frameObject
+	case tokenName:
+		switch prev.text {
+		case "do", "else", "try", "finally", "static":
+			return frameBlock
+		}
+		if regexpKeywords[prev.text] {
+			return frameObject
+		}
+	case tokenTemplate:
+		if prev.opens() {
+			return frameObject
+		}
+	}
+	return frameBlock
+}
+
+// declarators returns the names declared by the declarator list at i
+func (e *esm) declarators(i int) []int {
+	var names []int
+	for {
+		found, next := e.pattern(i)
+		if next == i {
+			return names
+		}
+		names = append(names, found...)
+
+		i = next
+		if e.at(i).is("=") {
+			i = e.expressionEnd(i + 1)
+		}
+		if !e.at(i).is(",") {
+			return names
+		}
+		i++
+	}
+}
+
+// params returns the names bound by the parameter list at i
+func (e *esm) params(i int) []int {
+	var names []int
+	end := e.match[i]
+
+	for j := i + 1; j < end; j++ {
+		if e.at(j).is("...") {
+			j++
+		}
+		found, next := e.pattern(j)
+		if next == j {
+			break
+		}
+		names = append(names, found...)
+
+		j = next
+		if e.at(j).is("=") {
+			j = e.expressionEnd(j + 1)
+		}
+		if !e.at(j).is(",") {
+			break
+		}
+	}
+	return names
+}
+
+// pattern returns the names bound by the binding pattern at i and the index
+// after it, the index is i when there is no pattern at i
+func (e *esm) pattern(i int) ([]int, int) {
+	t := e.at(i)
+	if t.kind == tokenName {
+		return []int{i}, i + 1
+	}
+	if !t.is("{") && !t.is("[") {
+		return nil, i
+	}
+
+	end := e.match[i]
+	if end < 0 {
+		return nil, len(e.tokens)
+	}
+	object := t.is("{")
+
+	var names []int
+	for j := i + 1; j < end; j++ {
+		if e.at(j).is(",") {
+			continue // holes
+		}
+		rest := e.at(j).is("...")
+		if rest {
+			j++
+		}
+
+		if object && !rest {
+			key := j
+			if e.at(j).is("[") && e.match[j] > 0 {
+				j = e.match[j]
+			}
+			j++
+			if e.at(j).is(":") {
+				found, next := e.pattern(j + 1)
+				names, j = append(names, found...), next
+			} else {
+				names = append(names, key)
+			}
+		} else {
+			found, next := e.pattern(j)
+			if next == j {
+				break
+			}
+			names, j = append(names, found...), next
+		}
+
+		if e.at(j).is("=") {
+			j = e.expressionEnd(j + 1)
+		}
+		if !e.at(j).is(",") {
+			break
+		}
+	}
+	return names, end + 1
+}
+
+// expressionEnd returns the index of the token ending the expression at i,
+// a comma, semicolon or closing bracket at its level, or the first token
+// after a line break the expression cannot continue over
+func (e *esm) expressionEnd(i int) int {
+	for start := i; i < len(e.tokens); i++ {
+		t := &e.tokens[i]
+		if t.is(",") || t.is(";") || closer(t) {
+			return i
+		}
+		if i > start && t.newline && !continues(&e.tokens[i-1], t) {
+			return i
+		}
+		for opener(&e.tokens[i]) {
+			if e.match[i] < 0 {
+				return len(e.tokens)
+			}
+			i = e.match[i]
+		}
+	}
+	return i
+}
+
+// apply returns the source with the edits applied behind prelude and records
+// the anchors mapping the generated columns back to the source
+func (e *esm) apply(prelude string) string {
+	sort.SliceStable(e.edits, func(i, j int) bool {
+		return e.edits[i].start < e.edits[j].start
+	})
+
+	var b strings.Builder
+	var gen, orig position
+	anchors := make(map[int][]anchor)
+	pin := func(fixed bool) {
+		anchors[gen.line] = append(anchors[gen.line], anchor{gen: gen.column, orig: orig.column, fixed: fixed})
+	}
+
+	pin(true)
+	b.WriteString(prelude)
+	gen.advance(prelude)
+	pin(false)
+
+	last := 0
+	for _, ed := range e.edits {
+		text := e.src[last:ed.start]
+		b.WriteString(text)
+		gen.advance(text)
+		orig.advance(text)
+
+		pin(true)
+		b.WriteString(ed.text)
+		gen.advance(ed.text)
+		orig.advance(e.src[ed.start:ed.end])
+		pin(false)
+		last = ed.end
+	}
+	b.WriteString(e.src[last:])
+
+	e.m.anchors = anchors
+	return b.String()
+}
+
+// matchBrackets returns the index of the bracket closing every opening one,
+// -1 for the other tokens and unclosed brackets
+func matchBrackets(tokens []token) []int {
+	match := make([]int, len(tokens))
+	var open []int
+
+	for i := range tokens {
+		match[i] = -1
+		t := &tokens[i]
+		if closer(t) && len(open) > 0 {
+			match[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+		}
+		if opener(t) {
+			open = append(open, i)
+		}
+	}
+	return match
+}
+
+func opener(t *token) bool {
+	return t.kind == tokenPunct && (t.text == "(" || t.text == "[" || t.text == "{") || t.opens()
+}
+
+func closer(t *token) bool {
+	return t.kind == tokenPunct && (t.text == ")" || t.text == "]" || t.text == "}") || t.closes()
+}
+
+// continues reports whether an expression continues from prev over a line
+// break to next, otherwise automatic semicolon insertion ends it
+func continues(prev, next *token) bool {
+	switch prev.kind {
+	case tokenPunct:
+		switch prev.text {
+		case ")", "]", "}", "++", "--":
+		default:
+			return true
+		}
+	case tokenName:
+		if regexpKeywords[prev.text] {
+			return true
+		}
+	case tokenTemplate:
+		if prev.opens() {
+			return true
+		}
+	}
+
+	switch next.kind {
+	case tokenPunct:
+		switch next.text {
+		case "{", "!", "~", "++", "--", "...":
+			return false
+		}
+		return true
+	case tokenTemplate:
+		return true
+	case tokenName:
+		return next.text == "in" || next.text == "instanceof"
+	}
+	return false
+}
+
+// property returns the expression reading the export name of namespace ns
+func property(ns, name string) string {
+	if name == "default" {
+		return "__godzilla_default(" + ns + ")"
+	}
+	if name == "" {
+		return ns + `[""]`
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' || !isNameStart(name[i]) && (i == 0 || !isDigit(name[i])) {
+			return ns + "[" + jsString(name) + "]"
+		}
+	}
+	return ns + "." + name
+}
+
+// unquote returns the value of the javascript string literal s
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, "'") && len(s) > 1 {
+		s = `"` + strings.NewReplacer(`\'`, `'`, `"`, `\"`).Replace(s[1:len(s)-1]) + `"`
+	}
+	return strconv.Unquote(s)
+}
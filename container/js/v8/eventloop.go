@@ -0,0 +1,236 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/eventloop.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"container/heap"
+	"sync/atomic"
+	"time"
+
+	"rogchap.com/v8go"
+)
+
+// minInterval is the shortest delay between the runs of an interval, like
+// browsers and node clamp it to
+const minInterval = time.Millisecond
+
+// timer is a callback scheduled with setTimeout or setInterval
+type timer struct {
+	id       int32
+	fn       *v8go.Function
+	args     []v8go.Valuer
+	when     time.Time
+	interval time.Duration
+	repeat   bool
+	index    int  // position in the heap, -1 while it is not scheduled
+	cleared  bool // set by clearTimeout and clearInterval
+}
+
+// timerHeap orders timers by the time they are due, then by creation
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].id < h[j].id
+	}
+	return h[i].when.Before(h[j].when)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+// eventLoop holds the timers of a vm, callbacks only ever run on the
+// goroutine evaluating the script so the isolate is never shared
+type eventLoop struct {
+	fetches int32 // fetch requests in flight, settled outside of the isolate
+	seq     int32
+	queue   timerHeap
+	timers  map[int32]*timer
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{
+		timers: make(map[int32]*timer),
+	}
+}
+
+// inject adds setTimeout, setInterval, clearTimeout and clearInterval to
+// global, along with the hooks counting the fetch requests in flight
+func (l *eventLoop) inject(isolate *v8go.Isolate, global *v8go.ObjectTemplate) error {
+	functions := map[string]v8go.FunctionCallback{
+		"setTimeout":    l.schedule(isolate, false),
+		"setInterval":   l.schedule(isolate, true),
+		"clearTimeout":  l.clear,
+		"clearInterval": l.clear,
+		"__godzilla_fetch_started": func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+			atomic.AddInt32(&l.fetches, 1)
+			return nil
+		},
+		"__godzilla_fetch_done": func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+			atomic.AddInt32(&l.fetches, -1)
+			return nil
+		},
+	}
+
+	for name, callback := range functions {
+		if err := global.Set(name, v8go.NewFunctionTemplate(isolate, callback)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *eventLoop) schedule(isolate *v8go.Isolate, repeat bool) v8go.FunctionCallback {
+	return func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		args := info.Args()
+		if len(args) == 0 || !args[0].IsFunction() {
+			return throw(info.Context(), "callback must be a function")
+		}
+
+		fn, err := args[0].AsFunction()
+		if err != nil {
+			return throw(info.Context(), err.Error())
+		}
+
+		var delay time.Duration
+		if len(args) > 1 && args[1].IsNumber() && args[1].Number() > 0 {
+			delay = time.Duration(args[1].Number() * float64(time.Millisecond))
+		}
+		if repeat && delay < minInterval {
+			delay = minInterval
+		}
+
+		l.seq++
+		t := &timer{
+			id:       l.seq,
+			fn:       fn,
+			when:     time.Now().Add(delay),
+			interval: delay,
+			repeat:   repeat,
+		}
+		if len(args) > 2 {
+			for _, arg := range args[2:] {
+				t.args = append(t.args, arg)
+			}
+		}
+
+		l.timers[t.id] = t
+		heap.Push(&l.queue, t)
+
+		id, _ := v8go.NewValue(isolate, t.id)
+		return id
+	}
+}
+
+func (l *eventLoop) clear(info *v8go.FunctionCallbackInfo) *v8go.Value {
+	if args := info.Args(); len(args) > 0 && args[0].IsNumber() {
+		if t, ok := l.timers[args[0].Int32()]; ok {
+			t.cleared = true
+			delete(l.timers, t.id)
+			if t.index >= 0 {
+				heap.Remove(&l.queue, t.index)
+			}
+		}
+	}
+	return nil
+}
+
+// runDue calls the timers that are due in the order they are due, timers
+// scheduled by these callbacks wait for the next turn. It returns the time
+// the next timer is due, or a zero time when no timers are pending.
+func (l *eventLoop) runDue(ctx *v8go.Context) (time.Time, error) {
+	now := time.Now()
+
+	var due []*timer
+	for len(l.queue) > 0 && !l.queue[0].when.After(now) {
+		due = append(due, heap.Pop(&l.queue).(*timer))
+	}
+
+	for _, t := range due {
+		// cleared by a callback that ran before in this turn
+		if t.cleared {
+			continue
+		}
+
+		if t.repeat {
+			t.when = now.Add(t.interval)
+			heap.Push(&l.queue, t)
+		} else {
+			delete(l.timers, t.id)
+		}
+
+		if _, err := t.fn.Call(ctx.Global(), t.args...); err != nil {
+			return time.Time{}, err
+		}
+		ctx.PerformMicrotaskCheckpoint()
+	}
+
+	if len(l.queue) == 0 {
+		return time.Time{}, nil
+	}
+	return l.queue[0].when, nil
+}
+
+// pending reports whether fetch requests are in flight
+func (l *eventLoop) pending() bool {
+	return atomic.LoadInt32(&l.fetches) > 0
+}
+
+// reset drops timers left over by a previous evaluation
+func (l *eventLoop) reset() {
+	for _, t := range l.queue {
+		t.index = -1
+	}
+	l.queue = nil
+	l.timers = make(map[int32]*timer)
+}
+
+// throw throws a javascript Error with msg
+func throw(ctx *v8go.Context, msg string) *v8go.Value {
+	iso := ctx.Isolate()
+	value, _ := v8go.NewValue(iso, msg)
+
+	if constructor, err := ctx.Global().Get("Error"); err == nil && constructor.IsFunction() {
+		fn, _ := constructor.AsFunction()
+		if errObject, err := fn.NewInstance(value); err == nil {
+			return iso.ThrowException(errObject.Value)
+		}
+	}
+	return iso.ThrowException(value)
+}
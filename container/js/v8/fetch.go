@@ -0,0 +1,286 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/fetch.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.kuoruan.net/v8go-polyfills/fetch"
+	"rogchap.com/v8go"
+)
+
+// fetchWrapper rewrites the url of every fetch call with __godzilla_fetch_target,
+// which routes same origin urls in process and enforces the host policy.
+// Remote requests are proxied in process, their failures reject the promise.
+const fetchWrapper = `(function (global, fetch, target, remote) {
+	global.fetch = function (input, init) {
+		try {
+			if (input !== null && typeof input === "object" && "url" in input) {
+				init = Object.assign({ method: input.method, headers: input.headers, body: input.body }, init);
+				input = input.url;
+			}
+			var url = target(String(input));
+			return fetch(url, init).then(function (res) {
+				var err = url.indexOf(remote) === 0 && res.headers.get("` + fetchErrorHeader + `");
+				if (err) {
+					throw new TypeError(err);
+				}
+				return res;
+			});
+		} catch (err) {
+			return Promise.reject(err);
+		}
+	};
+	delete global.__godzilla_fetch_target;
+})(globalThis, globalThis.fetch, globalThis.__godzilla_fetch_target, "` + remotePath + `?");`
+
+// fetchTracker wraps fetch so the event loop knows about the requests in
+// flight, their promises are settled outside of the isolate
+const fetchTracker = `(function (global, fetch, started, done) {
+	delete global.__godzilla_fetch_started;
+	delete global.__godzilla_fetch_done;
+	if (typeof fetch !== "function") {
+		return;
+	}
+	global.fetch = function () {
+		started();
+		try {
+			return Promise.resolve(fetch.apply(this, arguments)).then(function (res) {
+				done();
+				return res;
+			}, function (err) {
+				done();
+				throw err;
+			});
+		} catch (err) {
+			done();
+			return Promise.reject(err);
+		}
+	};
+})(globalThis, globalThis.fetch, globalThis.__godzilla_fetch_started, globalThis.__godzilla_fetch_done);`
+
+const (
+	// remotePath is the in process path remote requests are proxied
+	// through, so every redirect is checked against the allowed hosts
+	remotePath = "/__godzilla_fetch"
+
+	// fetchErrorHeader carries the error of a failed remote request
+	fetchErrorHeader = "X-Godzilla-Fetch-Error"
+
+	fetchTimeout = 30 * time.Second
+	maxRedirects = 10
+)
+
+// localAddr is the remote address of requests dispatched in process, it is
+// taken from the documentation range of RFC 5737 rather than loopback so ip
+// filters and trusted proxies allowing loopback don't trust scripts
+var localAddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1)}
+
+// FetchSettings holds the settings of the fetch function available to scripts
+type FetchSettings struct {
+	// Handler answering relative and same origin requests in process, e.g. gz.Handler()
+	Handler fasthttp.RequestHandler // default nil
+
+	// Origin of the app, absolute urls with this origin are sent to Handler
+	Origin string // default ""
+
+	// Hosts scripts may reach over the network, redirects included, "*"
+	// allows every host and "*.example.com" every subdomain of example.com
+	AllowedHosts []string // default nil (deny all)
+}
+
+// injectFetch adds the fetch polyfill to global
+func (vm *VM) injectFetch(global *v8go.ObjectTemplate) error {
+	settings := vm.settings.Fetch
+	if settings == nil {
+		return fetch.InjectTo(vm.isolate, global)
+	}
+
+	if err := fetch.InjectTo(vm.isolate, global, fetch.WithLocalHandler(newLocalHandler(settings))); err != nil {
+		return err
+	}
+
+	target := v8go.NewFunctionTemplate(vm.isolate, func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		var raw string
+		if args := info.Args(); len(args) > 0 {
+			raw = args[0].String()
+		}
+
+		rewritten, err := settings.target(raw)
+		if err != nil {
+			return throw(info.Context(), err.Error())
+		}
+
+		value, _ := v8go.NewValue(vm.isolate, rewritten)
+		return value
+	})
+	return global.Set("__godzilla_fetch_target", target)
+}
+
+// target returns the url fetch should request for raw, same origin urls are
+// made relative so they are dispatched to the in process handler and remote
+// urls are proxied through remotePath
+func (s *FetchSettings) target(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Host == "" {
+		if u.Path == remotePath {
+			return "", &url.Error{Op: "fetch", URL: raw, Err: errHostNotAllowed}
+		}
+		if s.Handler == nil {
+			return "", &url.Error{Op: "fetch", URL: raw, Err: errNoLocalHandler}
+		}
+		return raw, nil
+	}
+
+	if s.Handler != nil && s.Origin != "" {
+		if origin, err := url.Parse(s.Origin); err == nil &&
+			strings.EqualFold(origin.Host, u.Host) && (u.Scheme == "" || u.Scheme == origin.Scheme) {
+			return u.RequestURI(), nil
+		}
+	}
+
+	if !s.allowed(u.Hostname()) {
+		return "", &url.Error{Op: "fetch", URL: raw, Err: errHostNotAllowed}
+	}
+	return remotePath + "?" + url.Values{"url": {raw}}.Encode(), nil
+}
+
+// allowed reports whether host is in the allowed hosts
+func (s *FetchSettings) allowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range s.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == "*" || allowed == host:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// localHandler serves the requests of the fetch polyfill with a fasthttp
+// handler, remote requests are sent with client
+type localHandler struct {
+	settings *FetchSettings
+	client   *http.Client
+}
+
+func newLocalHandler(settings *FetchSettings) *localHandler {
+	return &localHandler{
+		settings: settings,
+		client: &http.Client{
+			Timeout: fetchTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errTooManyRedirects
+				}
+				if !settings.allowed(req.URL.Hostname()) {
+					return &url.Error{Op: "fetch", URL: req.URL.String(), Err: errHostNotAllowed}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func (h *localHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == remotePath {
+		h.remote(w, r)
+		return
+	}
+	if h.settings.Handler == nil {
+		http.Error(w, errNoLocalHandler.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req fasthttp.Request
+	req.Header.SetMethod(r.Method)
+	req.SetRequestURI(r.URL.RequestURI())
+
+	for key, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if u, err := url.Parse(h.settings.Origin); err == nil && u.Host != "" {
+		req.Header.SetHost(u.Host)
+	} else {
+		req.Header.SetHost("localhost")
+	}
+
+	if r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.SetBody(body)
+	}
+
+	var fctx fasthttp.RequestCtx
+	fctx.Init(&req, localAddr, nil)
+	h.settings.Handler(&fctx)
+
+	fctx.Response.Header.VisitAll(func(key, value []byte) {
+		w.Header().Add(string(key), string(value))
+	})
+	w.WriteHeader(fctx.Response.StatusCode())
+	w.Write(fctx.Response.Body())
+}
+
+// remote sends the request to the url of its query, a failure is reported
+// in fetchErrorHeader so the fetch wrapper rejects
+func (h *localHandler) remote(w http.ResponseWriter, r *http.Request) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, r.URL.Query().Get("url"), r.Body)
+	if err == nil {
+		req.Header = r.Header.Clone()
+		req.ContentLength = r.ContentLength
+
+		var res *http.Response
+		if res, err = h.client.Do(req); err == nil {
+			defer res.Body.Close()
+
+			for key, values := range res.Header {
+				for _, value := range values {
+					w.Header().Add(key, value)
+				}
+			}
+			w.WriteHeader(res.StatusCode)
+			io.Copy(w, res.Body)
+			return
+		}
+	}
+
+	w.Header().Set(fetchErrorHeader, err.Error())
+	w.WriteHeader(http.StatusBadGateway)
+}
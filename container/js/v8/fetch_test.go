@@ -0,0 +1,110 @@
+package v8
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TestFetchTarget tests fetch urls are routed in process, proxied or denied
+func TestFetchTarget(t *testing.T) {
+	settings := &FetchSettings{
+		Handler:      func(fctx *fasthttp.RequestCtx) {},
+		Origin:       "https://app.example.com",
+		AllowedHosts: []string{"api.example.com", "*.cdn.example.com"},
+	}
+
+	testCases := []struct {
+		raw    string
+		target string
+		err    error
+	}{
+		{raw: "/users?page=2", target: "/users?page=2"},
+		{raw: "https://app.example.com/users", target: "/users"},
+		{raw: "https://api.example.com/v1", target: remotePath + "?url=https%3A%2F%2Fapi.example.com%2Fv1"},
+		{raw: "https://img.cdn.example.com/a.png", target: remotePath + "?url=https%3A%2F%2Fimg.cdn.example.com%2Fa.png"},
+		{raw: "https://evil.example.com/", err: errHostNotAllowed},
+		{raw: "http://127.0.0.1:8080/", err: errHostNotAllowed},
+		{raw: remotePath + "?url=https%3A%2F%2Fevil.example.com", err: errHostNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		target, err := settings.target(tc.raw)
+		if !errors.Is(err, tc.err) || target != tc.target {
+			t.Fatalf("%s: returned %q, %v expected %q, %v", tc.raw, target, err, tc.target, tc.err)
+		}
+	}
+
+	if _, err := (&FetchSettings{}).target("/users"); !errors.Is(err, errNoLocalHandler) {
+		t.Fatalf("returned %v expected %v", err, errNoLocalHandler)
+	}
+}
+
+// TestFetchRedirect tests every redirect of a remote request is checked
+// against the allowed hosts
+func TestFetchRedirect(t *testing.T) {
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer denied.Close()
+
+	_, port, _ := net.SplitHostPort(denied.Listener.Addr().String())
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "http://localhost:"+port+"/", http.StatusFound)
+		case "/local":
+			http.Redirect(w, r, "/hello", http.StatusFound)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer allowed.Close()
+
+	var remoteIP string
+	settings := &FetchSettings{
+		Handler: func(fctx *fasthttp.RequestCtx) {
+			remoteIP = fctx.RemoteIP().String()
+		},
+		AllowedHosts: []string{"127.0.0.1"},
+	}
+	handler := newLocalHandler(settings)
+
+	testCases := []struct {
+		url  string
+		body string
+		err  string
+	}{
+		{url: allowed.URL + "/", body: "hello"},
+		{url: allowed.URL + "/local", body: "hello"},
+		{url: allowed.URL + "/redirect", err: "host is not allowed"},
+	}
+
+	for _, tc := range testCases {
+		target, err := settings.target(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+
+		if errText := w.Header().Get(fetchErrorHeader); !strings.Contains(errText, tc.err) || tc.err == "" && errText != "" {
+			t.Fatalf("%s: returned error %q expected %q", tc.url, errText, tc.err)
+		}
+		if tc.err == "" && w.Body.String() != tc.body {
+			t.Fatalf("%s: returned %q expected %q", tc.url, w.Body.String(), tc.body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+	if ip := net.ParseIP(remoteIP); ip == nil || ip.IsLoopback() || ip.IsPrivate() {
+		t.Fatalf("in process requests came from %q expected a non local address", remoteIP)
+	}
+}
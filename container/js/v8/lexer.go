@@ -0,0 +1,367 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/lexer.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenName     tokenKind = iota // identifiers and keywords
+	tokenPunct                     // operators and brackets
+	tokenString                    // string literals
+	tokenNumber                    // numeric literals
+	tokenTemplate                  // template literals, split at their substitutions
+	tokenRegexp                    // regular expression literals
+	tokenPrivate                   // private class member names, e.g. #count
+)
+
+// token is a javascript token, comments and white space are skipped
+type token struct {
+	kind    tokenKind
+	text    string
+	start   int  // byte offset of the token in the source
+	end     int  // byte offset after the token
+	line    int  // zero based line of start
+	column  int  // zero based byte column of start
+	newline bool // a line break precedes the token
+}
+
+// opens reports whether a template token is followed by a substitution
+func (t *token) opens() bool {
+	return t.kind == tokenTemplate && strings.HasSuffix(t.text, "${")
+}
+
+// closes reports whether a template token ends a substitution
+func (t *token) closes() bool {
+	return t.kind == tokenTemplate && strings.HasPrefix(t.text, "}")
+}
+
+// is reports whether t is the punctuator or name s
+func (t *token) is(s string) bool {
+	return (t.kind == tokenPunct || t.kind == tokenName) && t.text == s
+}
+
+// punctuators sorted so longer ones match first
+var punctuators = []string{
+	">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--", "+=", "-=",
+	"*=", "/=", "%=", "&=", "|=", "^=", "**", "<<", ">>",
+	"{", "}", "(", ")", "[", "]", ";", ",", "<", ">", "+", "-", "*", "/", "%",
+	"&", "|", "^", "!", "~", "?", ":", "=", ".", "@",
+}
+
+// regexpKeywords are the keywords a regular expression literal may follow
+var regexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// lexer splits javascript sources into tokens
+type lexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+	newline   bool   // a line break was skipped since the last token
+	preceded  bool   // a line break precedes the token being scanned
+	braces    []bool // open braces, true for template substitutions
+	tokens    []token
+}
+
+// tokenize returns the tokens of src
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src}
+
+	// a hashbang line is a comment
+	if strings.HasPrefix(src, "#!") {
+		l.skipLine()
+	}
+
+	for {
+		if err := l.skipSpace(); err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.src) {
+			return l.tokens, nil
+		}
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: "+format, append([]interface{}{l.line + 1, l.pos - l.lineStart + 1}, args...)...)
+}
+
+// breakLine records a line break at pos
+func (l *lexer) breakLine(pos int) {
+	l.line++
+	l.lineStart = pos + 1
+	l.newline = true
+}
+
+func (l *lexer) skipLine() {
+	for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+		l.pos++
+	}
+}
+
+// skipSpace skips white space and comments
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.breakLine(l.pos)
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "\u2028"), strings.HasPrefix(l.src[l.pos:], "\u2029"):
+			l.breakLine(l.pos + 2)
+			l.pos += 3
+		case strings.HasPrefix(l.src[l.pos:], "\u00a0"):
+			l.pos += 2
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += 3
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			l.skipLine()
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf("unterminated comment")
+			}
+			end += l.pos + 2
+			for i := l.pos; i < end; i++ {
+				if l.src[i] == '\n' {
+					l.breakLine(i)
+				}
+			}
+			l.pos = end + 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// emit appends the token from start to the current position
+func (l *lexer) emit(kind tokenKind, start, line, column int) {
+	l.tokens = append(l.tokens, token{
+		kind:    kind,
+		text:    l.src[start:l.pos],
+		start:   start,
+		end:     l.pos,
+		line:    line,
+		column:  column,
+		newline: l.preceded,
+	})
+	l.newline = false
+}
+
+func (l *lexer) next() error {
+	start, line, column := l.pos, l.line, l.pos-l.lineStart
+	l.preceded = l.newline
+	c := l.src[l.pos]
+
+	switch {
+	case isNameStart(c):
+		l.scanName()
+		l.emit(tokenName, start, line, column)
+	case c == '#' && l.pos+1 < len(l.src) && isNameStart(l.src[l.pos+1]):
+		l.pos++
+		l.scanName()
+		l.emit(tokenPrivate, start, line, column)
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		l.scanNumber()
+		l.emit(tokenNumber, start, line, column)
+	case c == '"' || c == '\'':
+		if err := l.scanString(c); err != nil {
+			return err
+		}
+		l.emit(tokenString, start, line, column)
+	case c == '`':
+		l.pos++
+		return l.scanTemplate(start, line, column)
+	case c == '}' && len(l.braces) > 0 && l.braces[len(l.braces)-1]:
+		l.braces = l.braces[:len(l.braces)-1]
+		l.pos++
+		return l.scanTemplate(start, line, column)
+	case c == '/' && l.regexpAllowed():
+		if err := l.scanRegexp(); err != nil {
+			return err
+		}
+		l.emit(tokenRegexp, start, line, column)
+	default:
+		for _, p := range punctuators {
+			if !strings.HasPrefix(l.src[l.pos:], p) {
+				continue
+			}
+			// a?.5:1 is a conditional
+			if p == "?." && l.pos+2 < len(l.src) && isDigit(l.src[l.pos+2]) {
+				continue
+			}
+
+			switch p {
+			case "{":
+				l.braces = append(l.braces, false)
+			case "}":
+				if len(l.braces) > 0 {
+					l.braces = l.braces[:len(l.braces)-1]
+				}
+			}
+			l.pos += len(p)
+			l.emit(tokenPunct, start, line, column)
+			return nil
+		}
+		return l.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+func (l *lexer) scanName() {
+	for l.pos < len(l.src) && (isNameStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+		if l.src[l.pos] == '\\' {
+			l.pos++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) scanNumber() {
+	radix := strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") ||
+		strings.HasPrefix(l.src[l.pos:], "0b") || strings.HasPrefix(l.src[l.pos:], "0B") ||
+		strings.HasPrefix(l.src[l.pos:], "0o") || strings.HasPrefix(l.src[l.pos:], "0O")
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isDigit(c) || isNameStart(c) || c == '.':
+		case (c == '+' || c == '-') && !radix && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E'):
+		default:
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) scanString(quote byte) error {
+	for l.pos++; l.pos < len(l.src); l.pos++ {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.breakLine(l.pos)
+			}
+		case '\n':
+			return l.errorf("unterminated string")
+		case quote:
+			l.pos++
+			return nil
+		}
+	}
+	return l.errorf("unterminated string")
+}
+
+// scanTemplate scans a template chunk up to its end or its next substitution
+func (l *lexer) scanTemplate(start, line, column int) error {
+	for ; l.pos < len(l.src); l.pos++ {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.breakLine(l.pos)
+			}
+		case '\n':
+			l.breakLine(l.pos)
+		case '`':
+			l.pos++
+			l.emit(tokenTemplate, start, line, column)
+			return nil
+		case '$':
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '{' {
+				l.pos += 2
+				l.braces = append(l.braces, true)
+				l.emit(tokenTemplate, start, line, column)
+				return nil
+			}
+		}
+	}
+	return l.errorf("unterminated template literal")
+}
+
+func (l *lexer) scanRegexp() error {
+	class := false
+	for l.pos++; l.pos < len(l.src); l.pos++ {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos++
+		case '\n':
+			return l.errorf("unterminated regular expression")
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				l.pos++
+				l.scanName() // flags
+				return nil
+			}
+		}
+	}
+	return l.errorf("unterminated regular expression")
+}
+
+// regexpAllowed reports whether a slash starts a regular expression literal
+// rather than a division, judged by the previous token
+func (l *lexer) regexpAllowed() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+
+	prev := &l.tokens[len(l.tokens)-1]
+	switch prev.kind {
+	case tokenName:
+		return regexpKeywords[prev.text]
+	case tokenPunct:
+		switch prev.text {
+		case ")", "]", "++", "--":
+			return false
+		}
+		return true
+	case tokenTemplate:
+		return prev.opens()
+	}
+	return false
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,337 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/module.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// moduleRegistry defines the functions modules are registered and imported with
+const moduleRegistry = `(function (global) {
+	var definitions = {}, cache = {};
+	global.__godzilla_define = function (path, factory) {
+		definitions[path] = factory;
+		delete cache[path];
+	};
+	global.__godzilla_import = function (path) {
+		if (cache[path]) {
+			return cache[path].exports;
+		}
+		var factory = definitions[path];
+		if (!factory) {
+			throw new Error("cannot find module '" + path + "'");
+		}
+		var module = cache[path] = { exports: {} };
+		factory(global.__godzilla_import, module.exports, module, module.exports);
+		return module.exports;
+	};
+	global.__godzilla_default = function (ns) {
+		return ns !== null && typeof ns === "object" && "default" in ns ? ns.default : ns;
+	};
+	global.__godzilla_reexport = function (target, source, names) {
+		Object.keys(names || source).forEach(function (name) {
+			var key = names ? names[name] : name;
+			// star exports skip the default and names the module exports itself
+			if (!names && (key === "default" || Object.prototype.hasOwnProperty.call(target, name))) {
+				return;
+			}
+			Object.defineProperty(target, name, {
+				enumerable: true,
+				get: function () { return source[key]; }
+			});
+		});
+	};
+})(globalThis);`
+
+// sourceMapURLPattern matches the source map comment of a module
+var sourceMapURLPattern = regexp.MustCompile(`(?m)^//[#@] sourceMappingURL=(\S+)\s*$`)
+
+// Loader resolves and transforms es modules read from a file system so they
+// can run in a vm, transformed modules are cached until their source changes.
+// Imports are hoisted and their statements blanked so line numbers are
+// preserved, names bound in an inner scope shadow the imported ones.
+type Loader struct {
+	fsys    fs.FS
+	mutex   sync.Mutex
+	modules map[string]*module
+}
+
+type module struct {
+	path    string
+	sum     [sha256.Size]byte
+	code    string           // transformed code defining the module
+	anchors map[int][]anchor // column anchors of the changed lines
+	deps    []string
+	smap    *sourceMap
+}
+
+// column maps the zero based column on line of the transformed code back to
+// the source of the module
+func (m *module) column(line, column int) int {
+	orig := column
+	for _, a := range m.anchors[line] {
+		if a.gen > column {
+			break
+		}
+		if a.fixed {
+			orig = a.orig
+		} else {
+			orig = a.orig + column - a.gen
+		}
+	}
+	return orig
+}
+
+// NewLoader returns a loader reading modules from fsys
+func NewLoader(fsys fs.FS) *Loader {
+	return &Loader{
+		fsys:    fsys,
+		modules: make(map[string]*module),
+	}
+}
+
+// Sum returns a checksum of the module at entry and everything it imports
+func (l *Loader) Sum(entry string) ([sha256.Size]byte, error) {
+	modules, err := l.graph(entry)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	h := sha256.New()
+	for _, m := range modules {
+		h.Write(m.sum[:])
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// graph returns the module at entry and its imports, dependencies first
+func (l *Loader) graph(entry string) ([]*module, error) {
+	var modules []*module
+	visited := make(map[string]bool)
+
+	var visit func(p string) error
+	visit = func(p string) error {
+		if visited[p] {
+			return nil
+		}
+		visited[p] = true
+
+		m, err := l.load(p)
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range m.deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		modules = append(modules, m)
+		return nil
+	}
+
+	if err := visit(path.Clean(strings.TrimPrefix(entry, "/"))); err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// load reads and transforms the module at p unless its source is unchanged
+func (l *Loader) load(p string) (*module, error) {
+	source, err := fs.ReadFile(l.fsys, p)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(source)
+
+	l.mutex.Lock()
+	cached, ok := l.modules[p]
+	l.mutex.Unlock()
+	if ok && cached.sum == sum {
+		return cached, nil
+	}
+
+	m, err := l.transform(p, string(source))
+	if err != nil {
+		return nil, err
+	}
+	m.sum = sum
+	m.smap = l.loadSourceMap(p, string(source))
+
+	l.mutex.Lock()
+	l.modules[p] = m
+	l.mutex.Unlock()
+	return m, nil
+}
+
+// lookup returns the cached module at p
+func (l *Loader) lookup(p string) *module {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.modules[p]
+}
+
+// resolve returns the path of the module imported as spec from the module at from
+func (l *Loader) resolve(from, spec string) (string, error) {
+	var candidate string
+	switch {
+	case strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../"):
+		candidate = path.Join(path.Dir(from), spec)
+	case strings.HasPrefix(spec, "/"):
+		candidate = path.Clean(spec[1:])
+	default:
+		return l.resolvePackage(from, spec)
+	}
+
+	if resolved, ok := l.resolveFile(candidate); ok {
+		return resolved, nil
+	}
+	return "", fmt.Errorf("v8: cannot resolve %q imported from %s", spec, from)
+}
+
+// resolvePackage resolves bare imports from the nearest node_modules directory
+func (l *Loader) resolvePackage(from, spec string) (string, error) {
+	name, subpath := spec, ""
+	segments := strings.SplitN(spec, "/", 3)
+	if strings.HasPrefix(spec, "@") && len(segments) > 1 {
+		name = segments[0] + "/" + segments[1]
+	} else {
+		name = segments[0]
+	}
+	subpath = strings.TrimPrefix(strings.TrimPrefix(spec, name), "/")
+
+	for dir := path.Dir(from); ; dir = path.Dir(dir) {
+		pkg := path.Join(dir, "node_modules", name)
+
+		if subpath != "" {
+			if resolved, ok := l.resolveFile(path.Join(pkg, subpath)); ok {
+				return resolved, nil
+			}
+		} else if resolved, ok := l.resolvePackageMain(pkg); ok {
+			return resolved, nil
+		}
+
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	return "", fmt.Errorf("v8: cannot resolve package %q imported from %s", spec, from)
+}
+
+// resolvePackageMain resolves the entry of the package in directory pkg
+func (l *Loader) resolvePackageMain(pkg string) (string, bool) {
+	if manifest, err := fs.ReadFile(l.fsys, path.Join(pkg, "package.json")); err == nil {
+		var fields struct {
+			Module string `json:"module"`
+			Main   string `json:"main"`
+		}
+		if json.Unmarshal(manifest, &fields) == nil {
+			for _, entry := range []string{fields.Module, fields.Main} {
+				if entry == "" {
+					continue
+				}
+				if resolved, ok := l.resolveFile(path.Join(pkg, entry)); ok {
+					return resolved, true
+				}
+			}
+		}
+	}
+	return l.resolveFile(pkg)
+}
+
+// resolveFile tries candidate as a file, with extensions and as a directory index
+func (l *Loader) resolveFile(candidate string) (string, bool) {
+	for _, p := range []string{
+		candidate,
+		candidate + ".js",
+		candidate + ".mjs",
+		path.Join(candidate, "index.js"),
+		path.Join(candidate, "index.mjs"),
+	} {
+		if info, err := fs.Stat(l.fsys, p); err == nil && !info.IsDir() {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// jsString quotes s as a javascript string literal
+func jsString(s string) string {
+	raw, _ := json.Marshal(s)
+	return string(raw)
+}
+
+// jsObject formats names as a javascript object literal of strings
+func jsObject(names map[string]string) string {
+	raw, _ := json.Marshal(names)
+	return string(raw)
+}
+
+// Import runs the module at path of loader with its imports, the module
+// exports are assigned to the global name unless it is empty. Errors thrown
+// by imported modules are mapped back to their original sources.
+func (vm *VM) Import(loader *Loader, path, name string) error {
+	modules, err := loader.graph(path)
+	if err != nil {
+		return err
+	}
+
+	if vm.modules == nil {
+		if _, err := vm.context.RunScript(moduleRegistry, "registry.js"); err != nil {
+			return err
+		}
+		vm.modules = make(map[string][sha256.Size]byte)
+	}
+	vm.loader = loader
+
+	for _, m := range modules {
+		if sum, ok := vm.modules[m.path]; ok && sum == m.sum {
+			continue
+		}
+		if err := vm.Script(m.path, m.code); err != nil {
+			return err
+		}
+		vm.modules[m.path] = m.sum
+	}
+
+	if vm.path == "" {
+		vm.path = path
+		defer func() { vm.path = "" }()
+	}
+
+	expr := "__godzilla_import(" + jsString(modules[len(modules)-1].path) + ")"
+	if name != "" {
+		expr = "globalThis[" + jsString(name) + "] = " + expr
+	}
+
+	if _, err := vm.context.RunScript(expr, path); err != nil {
+		return vm.rewrite(err)
+	}
+	return nil
+}
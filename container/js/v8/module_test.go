@@ -0,0 +1,152 @@
+package v8
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestImport tests es modules keep their exports, live bindings and cycles
+func TestImport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/values.js": {Data: []byte(`export const { a, b: [c] } = { a: 1, b: [2] }, d = 3;
+export let count = 0;
+export function increment() { count++; }
+export default class Counter {}
+const text = ` + "`" + `
+import { missing } from "./missing.js";
+` + "`" + `;
+// import { missing } from "./missing.js";
+/* export { missing }; */
+export { text as "quoted name" };`)},
+		"lib/cycle_a.js": {Data: []byte(`import { b } from "./cycle_b.js";
+export const a = () => "a" + b();`)},
+		"lib/cycle_b.js": {Data: []byte(`import { a } from "./cycle_a.js";
+export function b() { return "b"; }
+export const viaA = () => a();`)},
+		"main.js": {Data: []byte(`import Counter, { a, c, d, count, increment, "quoted name" as text } from "./lib/values.js";
+import * as values from "./lib/values.js";
+import { a as cycle } from "./lib/cycle_a.js";
+export * from "./lib/cycle_b.js";
+export { increment as bump } from "./lib/values.js";
+
+increment();
+const shorthand = { count, [a]: c, label: { count } };
+label: for (const n of [1]) { break label; }
+const shadowed = [
+	((a) => a)(10),
+	(function (c) { return c; })(20),
+	(() => { let d = 30; { const a = 0; } return d + a; })(),
+	[0].map(function d() { return typeof d; })[0],
+	(() => { if (a) { var c = 40; } return c; })(),
+	(() => { try { throw { a: 50 }; } catch ({ a }) { return a; } })(),
+	(() => { for (let d of [60]) { return d; } })(),
+	(() => { const before = d; var d = 70; return before; })(),
+	new (class c { name() { return typeof c; } })().name(),
+	{ d: 80 }.d + d,
+];
+export const result = {
+	sum: a + c + d,
+	count,
+	live: values.count,
+	counter: typeof Counter,
+	instance: new Counter() instanceof Counter,
+	shorthand: shorthand.count + shorthand[1] + shorthand.label.count,
+	text: text.trim(),
+	cycle: cycle(),
+	shadowed,
+};
+export default function () { return count; }`)},
+	}
+
+	vm, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	if err := vm.Import(NewLoader(fsys), "main.js", "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		expr   string
+		result string
+	}{
+		{expr: "JSON.stringify(main.result)", result: `{"sum":6,"count":1,"live":1,"counter":"function","instance":true,"shorthand":4,"text":"import { missing } from \"./missing.js\";","cycle":"ab","shadowed":[10,20,31,"function",40,50,60,null,"function",83]}`},
+		{expr: "main.bump(), main.default()", result: "2"},
+		{expr: "main.viaA()", result: "ab"},
+		{expr: "Object.keys(main).sort().join()", result: "b,bump,default,result,viaA"},
+	}
+
+	for _, tc := range testCases {
+		if result, err := vm.Eval("main.js", tc.expr); err != nil || result != tc.result {
+			t.Fatalf("%s: returned %q, %v expected %q", tc.expr, result, err, tc.result)
+		}
+	}
+}
+
+// TestImportUnsupported tests forms the transform cannot keep are rejected
+func TestImportUnsupported(t *testing.T) {
+	testCases := []struct {
+		source string
+		err    string
+	}{
+		{source: "import x from \"./x.js\";\nconst { y: x } = {};", err: `main.js:2: "x" redeclares an imported binding`},
+		{source: `import { x } from "./x.js"; function x() {}`, err: `main.js:1: "x" redeclares an imported binding`},
+		{source: `import data from "./x.js" with { type: "json" };`, err: "import attributes are not supported"},
+		{source: `export = 1;`, err: "main.js:1: unsupported export"},
+		{source: "const s = 'unterminated\n';", err: "main.js:1:24: unterminated string"},
+	}
+
+	for _, tc := range testCases {
+		fsys := fstest.MapFS{
+			"main.js": {Data: []byte(tc.source)},
+			"x.js":    {Data: []byte(`export const x = 1; export default 2;`)},
+		}
+		if _, err := NewLoader(fsys).Sum("main.js"); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: returned %v expected %q", tc.source, err, tc.err)
+		}
+	}
+}
+
+// TestImportStackTrace tests stack frames of modules point at their sources
+func TestImportStackTrace(t *testing.T) {
+	explode := `import { prefix } from "./prefix.js"; export const explode = () => { const p = prefix; throw new Error(p); };`
+	fsys := fstest.MapFS{
+		"lib/prefix.js":     {Data: []byte(`export const prefix = "boom";`)},
+		"lib/explode.js":    {Data: []byte(explode)},
+		"lib/mapped.js":     {Data: []byte("export const explode = () => { throw new Error('mapped'); };\n//# sourceMappingURL=mapped.js.map")},
+		"lib/mapped.js.map": {Data: []byte(`{"version":3,"sources":["../src/mapped.ts"],"mappings":"AAIE"}`)},
+		"main.js":           {Data: []byte("import { explode } from \"./lib/explode.js\";\nimport * as mapped from \"./lib/mapped.js\";\nexport { explode, mapped };")},
+	}
+
+	vm, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	if err := vm.Import(NewLoader(fsys), "main.js", "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		expr     string
+		location string
+	}{
+		{expr: "main.explode()", location: "lib/explode.js:1:" + strconv.Itoa(strings.Index(explode, "new Error")+1)},
+		{expr: "main.mapped.explode()", location: "src/mapped.ts:5:3"},
+	}
+
+	for _, tc := range testCases {
+		_, err := vm.EvalContext(context.Background(), "stack.js", tc.expr)
+		var jsErr *Error
+		if !errors.As(err, &jsErr) || !strings.Contains(jsErr.StackTrace, tc.location) {
+			t.Fatalf("%s: returned %v expected a frame at %s", tc.expr, err, tc.location)
+		}
+	}
+}
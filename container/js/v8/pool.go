@@ -0,0 +1,291 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/pool.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned when checking out a vm from a closed pool
+var ErrPoolClosed = errors.New("v8: pool is closed")
+
+// PoolSettings holds the pool settings
+type PoolSettings struct {
+	// Number of isolates kept warm in the pool
+	Size int // default runtime.NumCPU()
+
+	// Recycle an isolate after it served this many evaluations
+	MaxEvals int // default 0 (unlimited)
+
+	// Recycle an isolate once its used heap grows beyond this many bytes
+	MaxHeapSize uint64 // default 0 (unlimited)
+
+	// How often idle isolates are health checked
+	HealthCheckInterval time.Duration // default 0 (disabled)
+
+	// Settings of every pooled vm
+	VM *Settings // default &Settings{}
+}
+
+// Pool is a bounded set of pre-warmed VMs sharing the same compiled bundle.
+// A VM is not safe for concurrent use, so every caller checks out its own
+// VM with Get and returns it with Put.
+type Pool struct {
+	factory  func() (*VM, error)
+	settings *PoolSettings
+	vms      chan *VM
+	mutex    sync.Mutex
+	closed   bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPool compiles code into settings.Size isolates and returns the pool
+func NewPool(path, code string, settings ...*PoolSettings) (*Pool, error) {
+	return newPool(settings, func(vmSettings *Settings) (*VM, error) {
+		return Compile(path, code, vmSettings)
+	})
+}
+
+// NewModulePool imports the module at path of loader into settings.Size
+// isolates, its exports are assigned to the global name unless it is empty
+func NewModulePool(loader *Loader, path, name string, settings ...*PoolSettings) (*Pool, error) {
+	return newPool(settings, func(vmSettings *Settings) (*VM, error) {
+		vm, err := Load(vmSettings)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := vm.Import(loader, path, name); err != nil {
+			vm.Close()
+			return nil, err
+		}
+		return vm, nil
+	})
+}
+
+// newPool fills a pool with vms created by factory
+func newPool(settings []*PoolSettings, factory func(*Settings) (*VM, error)) (*Pool, error) {
+	p := &Pool{
+		settings: &PoolSettings{},
+		done:     make(chan struct{}),
+	}
+
+	// defaults are filled in on a copy, the settings may be shared
+	if len(settings) > 0 && settings[0] != nil {
+		*p.settings = *settings[0]
+	}
+
+	if p.settings.Size <= 0 {
+		p.settings.Size = runtime.NumCPU()
+	}
+
+	if p.settings.VM == nil {
+		p.settings.VM = &Settings{}
+	}
+
+	p.factory = func() (*VM, error) {
+		return factory(p.settings.VM)
+	}
+
+	p.vms = make(chan *VM, p.settings.Size)
+	for i := 0; i < p.settings.Size; i++ {
+		vm, err := p.factory()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.vms <- vm
+	}
+
+	if p.settings.HealthCheckInterval > 0 {
+		p.wg.Add(1)
+		go p.healthCheck()
+	}
+	return p, nil
+}
+
+// Get checks out a healthy vm, waiting until one is available or ctx is done
+func (p *Pool) Get(ctx context.Context) (*VM, error) {
+	for {
+		select {
+		case <-p.done:
+			return nil, ErrPoolClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case vm := <-p.vms:
+			if vm.healthy() {
+				return vm, nil
+			}
+			p.discard(vm)
+		}
+	}
+}
+
+// Put checks vm back in, recycling it when it is worn out or broken
+func (p *Pool) Put(vm *VM) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		vm.Close()
+		return
+	}
+
+	if p.worn(vm) || !vm.healthy() {
+		p.replace(vm)
+		return
+	}
+	p.vms <- vm
+}
+
+// Eval checks out a vm, evaluates expr and checks the vm back in
+func (p *Pool) Eval(path, expr string) (string, error) {
+	return p.EvalContext(context.Background(), path, expr)
+}
+
+// EvalContext is like Eval but waits for a vm and evaluates expr within ctx
+func (p *Pool) EvalContext(ctx context.Context, path, expr string) (string, error) {
+	vm, err := p.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer p.Put(vm)
+	return vm.EvalContext(ctx, path, expr)
+}
+
+// Close disposes every idle isolate, vms still checked out are disposed on Put
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mutex.Unlock()
+
+	// wait for health checks and replacements in flight
+	p.wg.Wait()
+
+	for {
+		select {
+		case vm := <-p.vms:
+			vm.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// worn reports whether vm reached one of the recycling limits
+func (p *Pool) worn(vm *VM) bool {
+	if p.settings.MaxEvals > 0 && vm.evals >= p.settings.MaxEvals {
+		return true
+	}
+
+	if p.settings.MaxHeapSize > 0 &&
+		vm.isolate.GetHeapStatistics().UsedHeapSize > p.settings.MaxHeapSize {
+		return true
+	}
+	return false
+}
+
+// discard replaces a broken vm unless the pool has been closed meanwhile
+func (p *Pool) discard(vm *VM) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		vm.Close()
+		return
+	}
+	p.replace(vm)
+}
+
+// replace disposes vm and compiles a fresh one in the background, the caller
+// must hold the mutex
+func (p *Pool) replace(vm *VM) {
+	vm.Close()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		for {
+			fresh, err := p.factory()
+			if err == nil {
+				p.checkin(fresh)
+				return
+			}
+
+			// the vm was created before, so keep retrying until closed
+			select {
+			case <-p.done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+}
+
+// checkin returns a fresh vm to the pool unless it has been closed meanwhile
+func (p *Pool) checkin(vm *VM) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		vm.Close()
+		return
+	}
+	p.vms <- vm
+}
+
+// healthCheck periodically checks idle vms and replaces broken ones
+func (p *Pool) healthCheck() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.settings.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		for i := len(p.vms); i > 0; i-- {
+			select {
+			case vm := <-p.vms:
+				if vm.healthy() {
+					p.checkin(vm)
+				} else {
+					p.discard(vm)
+				}
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package v8
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const counterBundle = `var counter = 0; function hit() { return ++counter; }`
+
+// TestPool tests concurrent checkouts share the compiled bundle
+func TestPool(t *testing.T) {
+	pool, err := NewPool("bundle.js", counterBundle, &PoolSettings{Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, err := pool.Eval("hit.js", "hit()"); err != nil || result == "" {
+				t.Errorf("returned %q, %v expected a count", result, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestPoolGet tests checkouts wait for a free vm until their context is done
+func TestPoolGet(t *testing.T) {
+	pool, err := NewPool("bundle.js", counterBundle, &PoolSettings{Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("returned %v expected %v", err, context.DeadlineExceeded)
+	}
+
+	pool.Put(vm)
+	if vm, err = pool.Get(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(vm)
+
+	pool.Close()
+	if _, err := pool.Get(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("returned %v expected %v", err, ErrPoolClosed)
+	}
+}
+
+// TestPoolRecycle tests worn out and broken vms are replaced with fresh ones
+func TestPoolRecycle(t *testing.T) {
+	pool, err := NewPool("bundle.js", counterBundle, &PoolSettings{Size: 1, MaxEvals: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for i, expected := range []string{"1", "2", "1", "2"} {
+		if result, err := pool.Eval("hit.js", "hit()"); err != nil || result != expected {
+			t.Fatalf("%d: returned %q, %v expected %q", i, result, err, expected)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.EvalContext(ctx, "loop.js", "while (true) {}"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("returned %v expected %v", err, context.DeadlineExceeded)
+	}
+
+	// the terminated vm is replaced in the background
+	if result, err := pool.Eval("hit.js", "hit()"); err != nil || result != "1" {
+		t.Fatalf("returned %q, %v expected a fresh vm", result, err)
+	}
+}
+
+// TestPoolHealthCheck tests idle vms that broke are replaced by the health check
+func TestPoolHealthCheck(t *testing.T) {
+	pool, err := NewPool("bundle.js", counterBundle, &PoolSettings{Size: 1, HealthCheckInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	vm, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Eval("hit.js", "hit()")
+
+	// break the vm without Put noticing, as a late termination would
+	vm.broken = true
+	pool.vms <- vm
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+
+		pool.mutex.Lock()
+		select {
+		case idle := <-pool.vms:
+			pool.vms <- idle
+			if idle != vm {
+				pool.mutex.Unlock()
+				return
+			}
+		default:
+		}
+		pool.mutex.Unlock()
+	}
+	t.Fatal("broken idle vm was not replaced")
+}
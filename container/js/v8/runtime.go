@@ -0,0 +1,248 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/runtime.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/godzillaframework/godzilla/container/js"
+)
+
+var _ js.Runtime = (*Runtime)(nil)
+
+// handlerSetup defines __godzilla_handle with the handler exported by the
+// module imported as __godzilla_handler, either as default or module.exports
+const handlerSetup = `(function (handler) {
+	if (typeof handler !== "function") {
+		throw new TypeError("script must export a handler function");
+	}
+	globalThis.__godzilla_handle = function (req) {
+		return Promise.resolve(handler(req));
+	};
+})(typeof __godzilla_handler === "function" ? __godzilla_handler : __godzilla_handler.default);`
+
+// RuntimeSettings holds the runtime settings
+type RuntimeSettings struct {
+	// Recompile scripts whenever their source changes
+	Development bool // default false
+
+	// Settings of the vm pool kept for every script
+	Pool *PoolSettings // default &PoolSettings{}
+}
+
+// Runtime serves requests with handler modules read from a file system,
+// every script gets its own pool of vms. Godzilla closes the runtime it is
+// given in Settings.JSRuntime when it stops.
+type Runtime struct {
+	loader   *Loader
+	settings *RuntimeSettings
+	mutex    sync.Mutex
+	closed   bool
+	scripts  map[string]*handlerScript
+}
+
+// handlerScript is the pool of a script, its mutex makes concurrent requests
+// wait for a single compilation without blocking other scripts
+type handlerScript struct {
+	mutex  sync.Mutex
+	pool   *Pool
+	err    error // compile error of the current sources
+	sum    [sha256.Size]byte
+	files  map[string]time.Time // modification times of the module and its imports
+	closed bool
+}
+
+// NewRuntime returns a runtime loading scripts from fsys
+func NewRuntime(fsys fs.FS, settings ...*RuntimeSettings) *Runtime {
+	r := &Runtime{
+		loader:   NewLoader(fsys),
+		settings: &RuntimeSettings{},
+		scripts:  make(map[string]*handlerScript),
+	}
+
+	if len(settings) > 0 && settings[0] != nil {
+		*r.settings = *settings[0]
+	}
+	return r
+}
+
+// Handle calls the handler exported by script with req
+func (r *Runtime) Handle(ctx context.Context, script string, req *js.Request) (*js.Response, error) {
+	var pool *Pool
+	var vm *VM
+	for {
+		var err error
+		if pool, err = r.pool(script); err != nil {
+			return nil, err
+		}
+
+		// the pool is closed when the script is recompiled meanwhile
+		vm, err = pool.Get(ctx)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrPoolClosed) {
+			return nil, err
+		}
+	}
+	defer pool.Put(vm)
+
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// console messages are attributed to the handler script
+	vm.path = script
+	defer func() { vm.path = "" }()
+
+	var res *js.Response
+	if err := vm.Decode(ctx, "handler.js", "__godzilla_handle("+string(raw)+")", &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Close disposes the vms of every script
+func (r *Runtime) Close() error {
+	r.mutex.Lock()
+	r.closed = true
+	scripts := r.scripts
+	r.scripts = make(map[string]*handlerScript)
+	r.mutex.Unlock()
+
+	for _, s := range scripts {
+		s.mutex.Lock()
+		s.closed = true
+		if s.pool != nil {
+			s.pool.Close()
+			s.pool = nil
+		}
+		s.mutex.Unlock()
+	}
+	return nil
+}
+
+// pool returns the pool of script, compiling it on first use and again
+// whenever it or one of its imports changed in development
+func (r *Runtime) pool(path string) (*Pool, error) {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return nil, ErrPoolClosed
+	}
+	s, ok := r.scripts[path]
+	if !ok {
+		s = &handlerScript{}
+		r.scripts[path] = s
+	}
+	r.mutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case s.closed:
+		return nil, ErrPoolClosed
+	case s.files != nil && (!r.settings.Development || !r.changed(s.files)):
+		return s.pool, s.err
+	}
+
+	// taken before compiling so changes made meanwhile are noticed next time
+	files, err := r.modTimes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := r.loader.Sum(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.pool != nil && s.err == nil && sum == s.sum {
+		s.files = files
+		return s.pool, nil
+	}
+
+	pool, err := newPool([]*PoolSettings{r.settings.Pool}, func(vmSettings *Settings) (*VM, error) {
+		vm, err := Load(vmSettings)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := vm.Import(r.loader, path, "__godzilla_handler"); err != nil {
+			vm.Close()
+			return nil, err
+		}
+
+		if err := vm.Script("handler.js", handlerSetup); err != nil {
+			vm.Close()
+			return nil, err
+		}
+		return vm, nil
+	})
+
+	// a broken script keeps failing with its error until it changes
+	s.sum, s.files, s.err = sum, files, err
+	if err != nil {
+		return nil, err
+	}
+
+	// vms still checked out of the old pool are disposed when put back
+	if s.pool != nil {
+		s.pool.Close()
+	}
+	s.pool = pool
+	return pool, nil
+}
+
+// modTimes returns the modification times of the module at path and its imports
+func (r *Runtime) modTimes(path string) (map[string]time.Time, error) {
+	modules, err := r.loader.graph(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]time.Time, len(modules))
+	for _, m := range modules {
+		info, err := fs.Stat(r.loader.fsys, m.path)
+		if err != nil {
+			return nil, err
+		}
+		files[m.path] = info.ModTime()
+	}
+	return files, nil
+}
+
+// changed reports whether one of files was modified or removed since
+func (r *Runtime) changed(files map[string]time.Time) bool {
+	for p, modTime := range files {
+		info, err := fs.Stat(r.loader.fsys, p)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package v8
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/godzillaframework/godzilla/container/js"
+)
+
+// TestRuntime tests handler scripts answer requests and are recompiled in
+// development once they change
+func TestRuntime(t *testing.T) {
+	fsys := fstest.MapFS{
+		"handlers/hello.js": {Data: []byte(`import { greet } from "../lib/greet.js";
+export default (req) => ({ status: 200, body: greet(req.params.name) });`), ModTime: time.Unix(1, 0)},
+		"lib/greet.js": {Data: []byte(`export const greet = (name) => "hello " + name;`), ModTime: time.Unix(1, 0)},
+	}
+
+	poolSettings := &PoolSettings{Size: 1}
+	runtime := NewRuntime(fsys, &RuntimeSettings{Development: true, Pool: poolSettings})
+	defer runtime.Close()
+
+	req := &js.Request{Method: "GET", Path: "/hello/godzilla", Params: map[string]string{"name": "godzilla"}}
+	handle := func(expected string) {
+		t.Helper()
+		res, err := runtime.Handle(context.Background(), "handlers/hello.js", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != 200 || res.Body != expected {
+			t.Fatalf("returned %d %v expected 200 %q", res.Status, res.Body, expected)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handle("hello godzilla")
+		}()
+	}
+	wg.Wait()
+
+	if poolSettings.VM != nil {
+		t.Fatal("pool defaults were written to the settings of the caller")
+	}
+
+	first, _ := runtime.pool("handlers/hello.js")
+
+	// touched without changes, the pool is kept
+	fsys["lib/greet.js"].ModTime = time.Unix(2, 0)
+	handle("hello godzilla")
+	if pool, _ := runtime.pool("handlers/hello.js"); pool != first {
+		t.Fatal("unchanged script was recompiled")
+	}
+
+	fsys["lib/greet.js"] = &fstest.MapFile{Data: []byte(`export const greet = (name) => "hi " + name;`), ModTime: time.Unix(3, 0)}
+	handle("hi godzilla")
+
+	fsys["lib/greet.js"] = &fstest.MapFile{Data: []byte(`export const greet = (name) => {`), ModTime: time.Unix(4, 0)}
+	if _, err := runtime.Handle(context.Background(), "handlers/hello.js", req); err == nil {
+		t.Fatal("broken script returned no error")
+	}
+
+	runtime.Close()
+	if _, err := runtime.Handle(context.Background(), "handlers/hello.js", req); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("returned %v expected %v", err, ErrPoolClosed)
+	}
+}
+
+// TestRuntimeProduction tests scripts are compiled once outside of development
+func TestRuntimeProduction(t *testing.T) {
+	fsys := fstest.MapFS{
+		"handler.js": {Data: []byte(`module.exports = function (req) { return { body: { method: req.method } }; };`)},
+	}
+
+	runtime := NewRuntime(fsys)
+	defer runtime.Close()
+
+	res, err := runtime.Handle(context.Background(), "handler.js", &js.Request{Method: "POST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, ok := res.Body.(map[string]interface{}); !ok || body["method"] != "POST" {
+		t.Fatalf("returned %v", res.Body)
+	}
+
+	fsys["handler.js"] = &fstest.MapFile{Data: []byte(`module.exports = function () { return null; };`), ModTime: time.Unix(5, 0)}
+	if res, err := runtime.Handle(context.Background(), "handler.js", &js.Request{Method: "POST"}); err != nil || res == nil {
+		t.Fatalf("returned %v, %v expected the compiled script to answer", res, err)
+	}
+}
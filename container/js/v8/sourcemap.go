@@ -0,0 +1,232 @@
+/**
+@author: Krisna Pranav, GodzillaFrameworkDevelopers
+@filename: container/js/v8/sourcemap.go
+
+Copyright [2021 - 2023] [Krisna Pranav, GodzillaFrameworkDeveloeprs]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v8
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const base64VLQ = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// stackLocationPattern matches the path:line:column locations of stack traces
+var stackLocationPattern = regexp.MustCompile(`([^\s()]+):(\d+):(\d+)`)
+
+// sourceMap is a decoded version 3 source map
+type sourceMap struct {
+	sources []string
+	lines   [][]mapping // mappings of every generated line
+}
+
+// mapping maps a generated column to an original location, all zero based
+type mapping struct {
+	column       int
+	source       int
+	sourceLine   int
+	sourceColumn int
+}
+
+// loadSourceMap loads the source map referenced by source of the module at p,
+// modules without a readable source map return nil
+func (l *Loader) loadSourceMap(p, source string) *sourceMap {
+	var raw []byte
+	var dir string
+
+	if match := sourceMapURLPattern.FindStringSubmatch(source); match != nil {
+		url := match[1]
+		if strings.HasPrefix(url, "data:") {
+			if i := strings.Index(url, ";base64,"); i >= 0 {
+				raw, _ = base64.StdEncoding.DecodeString(url[i+len(";base64,"):])
+			}
+			dir = path.Dir(p)
+		} else {
+			mapPath := path.Join(path.Dir(p), url)
+			raw, _ = fs.ReadFile(l.fsys, mapPath)
+			dir = path.Dir(mapPath)
+		}
+	} else {
+		raw, _ = fs.ReadFile(l.fsys, p+".map")
+		dir = path.Dir(p)
+	}
+
+	if raw == nil {
+		return nil
+	}
+
+	smap, err := parseSourceMap(raw, dir)
+	if err != nil {
+		return nil
+	}
+	return smap
+}
+
+// parseSourceMap decodes raw, resolving sources relative to dir
+func parseSourceMap(raw []byte, dir string) (*sourceMap, error) {
+	var fields struct {
+		Version    int      `json:"version"`
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+		Mappings   string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	if fields.Version != 3 {
+		return nil, errors.New("v8: unsupported source map version " + strconv.Itoa(fields.Version))
+	}
+
+	smap := &sourceMap{}
+	for _, source := range fields.Sources {
+		if !strings.Contains(source, "://") {
+			source = path.Join(dir, fields.SourceRoot, source)
+		}
+		smap.sources = append(smap.sources, source)
+	}
+
+	var source, sourceLine, sourceColumn int
+	for _, line := range strings.Split(fields.Mappings, ";") {
+		var mappings []mapping
+		column := 0
+
+		for _, segment := range strings.Split(line, ",") {
+			if segment == "" {
+				continue
+			}
+
+			values, err := decodeVLQ(segment)
+			if err != nil {
+				return nil, err
+			}
+
+			column += values[0]
+			if len(values) < 4 {
+				continue
+			}
+			source += values[1]
+			sourceLine += values[2]
+			sourceColumn += values[3]
+
+			mappings = append(mappings, mapping{
+				column:       column,
+				source:       source,
+				sourceLine:   sourceLine,
+				sourceColumn: sourceColumn,
+			})
+		}
+		smap.lines = append(smap.lines, mappings)
+	}
+	return smap, nil
+}
+
+// decodeVLQ decodes a base64 vlq encoded segment
+func decodeVLQ(segment string) ([]int, error) {
+	var values []int
+	value, shift := 0, 0
+
+	for i := 0; i < len(segment); i++ {
+		digit := strings.IndexByte(base64VLQ, segment[i])
+		if digit < 0 {
+			return nil, errors.New("v8: invalid source map mapping " + segment)
+		}
+
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+
+	if len(values) == 0 {
+		return nil, errors.New("v8: empty source map segment")
+	}
+	return values, nil
+}
+
+// lookup maps the zero based generated line and column to the original source
+func (s *sourceMap) lookup(line, column int) (source string, sourceLine, sourceColumn int, ok bool) {
+	if line < 0 || line >= len(s.lines) {
+		return "", 0, 0, false
+	}
+
+	mappings := s.lines[line]
+	i := sort.Search(len(mappings), func(i int) bool {
+		return mappings[i].column > column
+	}) - 1
+	if i < 0 {
+		return "", 0, 0, false
+	}
+
+	m := mappings[i]
+	if m.source >= len(s.sources) {
+		return "", 0, 0, false
+	}
+	return s.sources[m.source], m.sourceLine, m.sourceColumn, true
+}
+
+// rewrite maps the locations of err back to the original module sources
+func (vm *VM) rewrite(err error) error {
+	var jsErr *Error
+	if vm.loader == nil || !errors.As(err, &jsErr) {
+		return err
+	}
+
+	return &Error{
+		Message:    jsErr.Message,
+		Location:   vm.loader.rewriteLocations(jsErr.Location),
+		StackTrace: vm.loader.rewriteLocations(jsErr.StackTrace),
+	}
+}
+
+// rewriteLocations rewrites every path:line:column of s that points into a module
+func (l *Loader) rewriteLocations(s string) string {
+	return stackLocationPattern.ReplaceAllStringFunc(s, func(location string) string {
+		groups := stackLocationPattern.FindStringSubmatch(location)
+		m := l.lookup(groups[1])
+		if m == nil {
+			return location
+		}
+
+		line, _ := strconv.Atoi(groups[2])
+		column, _ := strconv.Atoi(groups[3])
+
+		column = m.column(line-1, column-1) + 1
+
+		if m.smap != nil {
+			if source, sourceLine, sourceColumn, ok := m.smap.lookup(line-1, column-1); ok {
+				return source + ":" + strconv.Itoa(sourceLine+1) + ":" + strconv.Itoa(sourceColumn+1)
+			}
+		}
+		return m.path + ":" + strconv.Itoa(line) + ":" + strconv.Itoa(column)
+	})
+}
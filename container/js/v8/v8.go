@@ -20,12 +20,12 @@ limitations under the License.
 package v8
 
 import (
-	"os"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
 
-	"github.com/livebud/bud/package/js"
-	"go.kuoruan.net/v8go-polyfills/console"
-	"go.kuoruan.net/v8go-polyfills/fetch"
-	"go.kuoruan.net/v8go-polyfills/timers"
+	"github.com/godzillaframework/godzilla/container/js"
 	"go.kuoruan.net/v8go-polyfills/url"
 	"rogchap.com/v8go"
 )
@@ -35,29 +35,237 @@ type Error = v8go.JSError
 
 var _ js.VM = (*VM)(nil)
 
+var (
+	// ErrHeapLimit is returned when an evaluation grows the heap past Settings.HeapLimit
+	ErrHeapLimit = errors.New("v8: isolate heap limit exceeded")
+
+	// ErrUnsettled is returned when a promise is pending while no timers or
+	// fetch requests are left that could settle it
+	ErrUnsettled = errors.New("v8: promise can never settle")
+
+	errHostNotAllowed   = errors.New("host is not allowed")
+	errNoLocalHandler   = errors.New("no local handler for relative urls")
+	errTooManyRedirects = errors.New("stopped after too many redirects")
+)
+
+// idleWait is the longest the event loop sleeps while waiting on work that
+// settles outside of the isolate, e.g. fetch requests
+const idleWait = 5 * time.Millisecond
+
+// Settings holds the vm settings
+type Settings struct {
+	// Maximum used heap size in bytes, checked after the script ran and
+	// between event loop turns, never while JavaScript runs since the isolate
+	// must not be used from another thread. A synchronous loop allocating
+	// without end is only stopped by the context deadline or by the heap size
+	// limit of v8 itself (HeapStatistics.HeapSizeLimit), which aborts the
+	// process, the limit must stay well below it.
+	HeapLimit uint64 // default 0 (unlimited)
+
+	// Go values bound with VM.Bind before any script runs, keyed by name
+	Bindings map[string]interface{} // default nil
+
+	// Routing and host policy of fetch, nil lets scripts fetch any url
+	Fetch *FetchSettings // default nil
+
+	// Cache of compiled scripts shared across vms and restarts
+	CodeCache *CodeCache // default nil (disabled)
+
+	// Receives the console messages of scripts
+	Console ConsoleSink // default StdConsole
+}
+
 type VM struct {
-	isolate *v8go.Isolate
-	context *v8go.Context
+	isolate  *v8go.Isolate
+	context  *v8go.Context
+	loop     *eventLoop
+	settings *Settings
+	ctx      context.Context // context of the running evaluation
+	path     string          // path of the running script
+	loader   *Loader         // loader of the imported modules, if any
+	modules  map[string][sha256.Size]byte
+	evals    int  // number of evaluations served, used to recycle pooled vms
+	broken   bool // set once execution has been terminated
+
 }
 
 func (vm *VM) Eval(path, expr string) (string, error) {
-	value, err := vm.context.RunScript(expr, path)
+	return vm.EvalContext(context.Background(), path, expr)
+}
+
+// EvalContext evaluates expr, driving microtasks, timers and fetch requests
+// until a returned promise settles, or until none are left when expr does not
+// return a promise. Execution is terminated when ctx is done or the heap limit
+// is exceeded, after which the vm must be closed.
+func (vm *VM) EvalContext(ctx context.Context, path, expr string) (string, error) {
+	value, err := vm.evalContext(ctx, path, expr)
 	if err != nil {
 		return "", err
 	}
+	return value.String(), nil
+}
+
+func (vm *VM) evalContext(ctx context.Context, path, expr string) (*v8go.Value, error) {
+	vm.evals++
+	vm.loop.reset()
+
+	vm.ctx = ctx
+	defer func() { vm.ctx = nil }()
+
+	if vm.path == "" {
+		vm.path = path
+		defer func() { vm.path = "" }()
+	}
+
+	stop := vm.watch(ctx)
+	defer stop()
+
+	value, err := vm.context.RunScript(expr, path)
+	if err != nil {
+		return nil, vm.rewrite(vm.abort(ctx, err))
+	}
 
+	var prom *v8go.Promise
 	if value.IsPromise() {
-		prom, err := value.AsPromise()
+		if prom, err = value.AsPromise(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := vm.settle(ctx, prom); err != nil {
+		return nil, err
+	}
+
+	if prom == nil {
+		return value, nil
+	}
+	if prom.State() == v8go.Rejected {
+		return nil, vm.rewrite(jsError(prom.Result()))
+	}
+	return prom.Result(), nil
+}
+
+// settle drives microtasks, timers and fetch requests until prom settles, or
+// when prom is nil until no timers and fetch requests are left. Intervals
+// that are never cleared keep it running until ctx is done.
+func (vm *VM) settle(ctx context.Context, prom *v8go.Promise) error {
+	settled := func() bool {
+		return prom != nil && prom.State() != v8go.Pending
+	}
+
+	for {
+		vm.context.PerformMicrotaskCheckpoint()
+
+		if err := vm.checkHeap(); err != nil {
+			return err
+		}
+		if settled() {
+			return nil
+		}
+
+		next, err := vm.loop.runDue(vm.context)
 		if err != nil {
-			return "", err
+			return vm.rewrite(vm.abort(ctx, err))
+		}
+		if settled() {
+			return nil
+		}
+
+		if next.IsZero() && !vm.loop.pending() {
+			if prom == nil {
+				return nil
+			}
+			// nothing is left that could resolve or reject prom
+			return ErrUnsettled
+		}
+
+		wait := idleWait
+		if !next.IsZero() {
+			if untilNext := time.Until(next); untilNext < wait {
+				wait = untilNext
+			}
 		}
 
-		for prom.State() == v8go.Pending {
-			continue
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
 		}
-		return prom.Result().String(), nil
 	}
-	return value.String(), nil
+}
+
+// evalCtx returns the context bound functions are called with
+func (vm *VM) evalCtx() context.Context {
+	if vm.ctx == nil {
+		return context.Background()
+	}
+	return vm.ctx
+}
+
+// watch terminates execution once ctx is done, the returned func stops
+// watching. TerminateExecution is the only isolate call v8 allows from
+// another thread.
+func (vm *VM) watch(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+
+		select {
+		case <-ctx.Done():
+			vm.isolate.TerminateExecution()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+
+		// termination may hit the isolate after the script finished
+		if ctx.Err() != nil {
+			vm.broken = true
+		}
+	}
+}
+
+// abort translates errors caused by a terminated execution into ctx errors
+func (vm *VM) abort(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		vm.broken = true
+		return ctxErr
+	}
+	return err
+}
+
+// checkHeap enforces Settings.HeapLimit between event loop turns, on the
+// goroutine running the isolate
+func (vm *VM) checkHeap() error {
+	if vm.settings.HeapLimit == 0 {
+		return nil
+	}
+
+	if vm.isolate.GetHeapStatistics().UsedHeapSize > vm.settings.HeapLimit {
+		vm.broken = true
+		return ErrHeapLimit
+	}
+	return nil
+}
+
+// jsError converts a rejected promise value into an error
+func jsError(value *v8go.Value) error {
+	err := &Error{Message: value.String()}
+
+	if value.IsObject() {
+		if stack, e := value.Object().Get("stack"); e == nil && stack.IsString() {
+			err.StackTrace = stack.String()
+		}
+	}
+	return err
 }
 
 func Eval(path, code string) (string, error) {
@@ -65,88 +273,113 @@ func Eval(path, code string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	defer vm.Close()
 	return vm.Eval(path, code)
 }
 
-func load() (*v8go.Isolate, *v8go.Context, error) {
-	isolate := v8go.NewIsolate()
-	global := v8go.NewObjectTemplate(isolate)
-	if err := fetch.InjectTo(isolate, global); err != nil {
-		isolate.TerminateExecution()
-		isolate.Dispose()
-		return nil, nil, err
+func load(settings []*Settings) (*VM, error) {
+	vm := &VM{
+		loop:     newEventLoop(),
+		settings: &Settings{},
 	}
 
-	if err := timers.InjectTo(isolate, global); err != nil {
-		isolate.TerminateExecution()
-		isolate.Dispose()
-		return nil, nil, err
+	if len(settings) > 0 {
+		vm.settings = settings[0]
 	}
 
-	context := v8go.NewContext(isolate, global)
+	vm.isolate = v8go.NewIsolate()
+	global := v8go.NewObjectTemplate(vm.isolate)
+	if err := vm.injectFetch(global); err != nil {
+		vm.isolate.TerminateExecution()
+		vm.isolate.Dispose()
+		return nil, err
+	}
 
-	if err := url.InjectTo(context); err != nil {
-		context.Close()
-		isolate.TerminateExecution()
-		isolate.Dispose()
-		return nil, nil, err
+	if err := vm.loop.inject(vm.isolate, global); err != nil {
+		vm.isolate.TerminateExecution()
+		vm.isolate.Dispose()
+		return nil, err
 	}
 
-	if err := console.InjectMultipleTo(context,
-		console.NewConsole(console.WithOutput(os.Stderr), console.WithMethodName("error")),
-		console.NewConsole(console.WithOutput(os.Stderr), console.WithMethodName("warn")),
-		console.NewConsole(console.WithOutput(os.Stdout), console.WithMethodName("log")),
-	); err != nil {
-		context.Close()
-		isolate.TerminateExecution()
-		isolate.Dispose()
-		return nil, nil, err
+	vm.context = v8go.NewContext(vm.isolate, global)
+
+	if err := url.InjectTo(vm.context); err != nil {
+		vm.Close()
+		return nil, err
 	}
-	return isolate, context, nil
-}
 
-func Load() (*VM, error) {
-	isolate, context, err := load()
-	if err != nil {
+	if err := vm.injectConsole(); err != nil {
+		vm.Close()
 		return nil, err
 	}
-	return &VM{
-		isolate: isolate,
-		context: context,
-	}, nil
-}
 
-func Compile(path, code string) (*VM, error) {
-	isolate, context, err := load()
-	if err != nil {
+	if vm.settings.Fetch != nil {
+		if _, err := vm.context.RunScript(fetchWrapper, "fetch.js"); err != nil {
+			vm.Close()
+			return nil, err
+		}
+	}
+
+	if _, err := vm.context.RunScript(fetchTracker, "fetch.js"); err != nil {
+		vm.Close()
 		return nil, err
 	}
-	script, err := isolate.CompileUnboundScript(code, path, v8go.CompileOptions{})
+
+	for name, value := range vm.settings.Bindings {
+		if err := vm.Bind(name, value); err != nil {
+			vm.Close()
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+func Load(settings ...*Settings) (*VM, error) {
+	return load(settings)
+}
+
+func Compile(path, code string, settings ...*Settings) (*VM, error) {
+	vm, err := load(settings)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := script.Run(context); err != nil {
+	if err := vm.Script(path, code); err != nil {
+		vm.Close()
 		return nil, err
 	}
-	return &VM{
-		isolate: isolate,
-		context: context,
-	}, nil
+	return vm, nil
 }
 
 func (vm *VM) Script(path, code string) error {
-	script, err := vm.isolate.CompileUnboundScript(code, path, v8go.CompileOptions{})
+	if vm.path == "" {
+		vm.path = path
+		defer func() { vm.path = "" }()
+	}
+
+	script, refresh, err := vm.compile(path, code)
 	if err != nil {
-		return err
+		return vm.rewrite(err)
 	}
 
 	if _, err := script.Run(vm.context); err != nil {
-		return err
+		return vm.rewrite(err)
+	}
+	if refresh != nil {
+		refresh()
 	}
 	return nil
 }
 
+// healthy reports whether the vm can still evaluate scripts
+func (vm *VM) healthy() bool {
+	if vm.broken || vm.isolate.IsExecutionTerminating() {
+		return false
+	}
+	value, err := vm.context.RunScript("1", "health.js")
+	return err == nil && value.Int32() == 1
+}
+
 func (vm *VM) Close() error {
 	vm.context.Close()
 	vm.isolate.TerminateExecution()
@@ -0,0 +1,205 @@
+package v8
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEvalContext tests promises, timers and their order
+func TestEvalContext(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expr   string
+		result string
+		err    string
+	}{
+		{name: "value", expr: "1 + 1", result: "2"},
+		{name: "resolved", expr: "Promise.resolve('done')", result: "done"},
+		{name: "rejected", expr: "Promise.reject(new Error('failed'))", err: "Error: failed"},
+		{name: "thrown", expr: "throw new Error('thrown')", err: "Error: thrown"},
+		{
+			name: "timer order",
+			expr: `new Promise(function (resolve) {
+				var order = [];
+				setTimeout(function () { order.push("a"); }, 0);
+				setTimeout(function () { order.push("b"); }, 0);
+				setTimeout(function () { order.push("c"); resolve(order.join("")); }, 0);
+			})`,
+			result: "abc",
+		},
+		{
+			name: "delay order",
+			expr: `new Promise(function (resolve) {
+				var order = [];
+				setTimeout(function () { order.push("late"); resolve(order.join(",")); }, 20);
+				setTimeout(function () { order.push("early"); }, 5);
+			})`,
+			result: "early,late",
+		},
+		{
+			name: "scheduled during a turn",
+			expr: `new Promise(function (resolve) {
+				var order = [];
+				setTimeout(function () {
+					order.push("a");
+					setTimeout(function () { order.push("c"); resolve(order.join("")); }, 0);
+				}, 0);
+				setTimeout(function () { order.push("b"); }, 0);
+			})`,
+			result: "abc",
+		},
+		{
+			name: "clearTimeout",
+			expr: `new Promise(function (resolve) {
+				var fired = [];
+				var a = setTimeout(function () { fired.push("a"); }, 0);
+				setTimeout(function () { fired.push("b"); clearTimeout(c); }, 0);
+				var c = setTimeout(function () { fired.push("c"); }, 0);
+				clearTimeout(a);
+				setTimeout(function () { resolve(fired.join("")); }, 5);
+			})`,
+			result: "b",
+		},
+		{
+			name: "setInterval",
+			expr: `new Promise(function (resolve) {
+				var n = 0;
+				var id = setInterval(function () {
+					if (++n === 3) {
+						clearInterval(id);
+						resolve(n);
+					}
+				}, 0);
+			})`,
+			result: "3",
+		},
+		{
+			name: "timer error",
+			expr: `new Promise(function () {
+				setTimeout(function () { throw new Error("timer failed"); }, 0);
+			})`,
+			err: "timer failed",
+		},
+	}
+
+	vm, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	for _, tc := range testCases {
+		result, err := vm.EvalContext(context.Background(), "test.js", tc.expr)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%s: returned error %v expected %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || result != tc.result {
+			t.Fatalf("%s: returned %q, %v expected %q", tc.name, result, err, tc.result)
+		}
+	}
+}
+
+// TestEvalPendingTimers tests timers scheduled by scripts not returning a
+// promise still run, and promises nothing can settle fail
+func TestEvalPendingTimers(t *testing.T) {
+	vm, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	if _, err := vm.Eval("timers.js", "var fired = false; setTimeout(function () { fired = true; }, 5); 1"); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := vm.Eval("check.js", "fired"); err != nil || result != "true" {
+		t.Fatalf("returned %q, %v expected the timer to fire", result, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := vm.Eval("never.js", "new Promise(function () {})")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrUnsettled) {
+			t.Fatalf("returned %v expected %v", err, ErrUnsettled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("evaluation of a promise that never settles did not return")
+	}
+}
+
+// TestEvalDeadline tests execution is terminated once the context is done
+func TestEvalDeadline(t *testing.T) {
+	vm, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := vm.EvalContext(ctx, "loop.js", "while (true) {}"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("returned %v expected %v", err, context.DeadlineExceeded)
+	}
+	if vm.healthy() {
+		t.Fatal("terminated vm reported healthy")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	vm, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	if _, err := vm.EvalContext(ctx, "interval.js", "setInterval(function () {}, 0)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("returned %v expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestHeapLimit tests evaluations growing the heap past the limit fail once
+// the script ran and between event loop turns
+func TestHeapLimit(t *testing.T) {
+	testCases := []struct {
+		name string
+		expr string
+	}{
+		{name: "script", expr: `var chunks = []; for (var i = 0; i < 8192; i++) { chunks.push(new Array(1024).fill("godzilla")); }`},
+		{name: "timer", expr: `var later = []; new Promise(function (resolve) {
+	setTimeout(function () {
+		for (var i = 0; i < 8192; i++) { later.push(new Array(1024).fill("godzilla")); }
+		setTimeout(resolve, 10);
+	}, 0);
+})`},
+	}
+
+	for _, tc := range testCases {
+		vm, err := Load(&Settings{HeapLimit: 32 << 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = vm.Eval(tc.name+".js", tc.expr)
+		healthy := vm.healthy()
+		vm.Close()
+
+		if !errors.Is(err, ErrHeapLimit) {
+			t.Fatalf("%s: returned %v expected %v", tc.name, err, ErrHeapLimit)
+		}
+		if healthy {
+			t.Fatalf("%s: vm past its heap limit reported healthy", tc.name)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package godzilla
 
 import (
+	gocontext "context"
 	"fmt"
 	"strings"
+	"sync"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/valyala/fasthttp"
@@ -27,6 +29,13 @@ type Context interface {
 	GetLocal(key string) interface{}
 	Body() string
 	ParseBody(out interface{}) error
+	Route() string
+	Ctx() gocontext.Context
+	RequestID() string
+	IP() string
+	IPs() []string
+	Scheme() string
+	Host() string
 }
 
 type handlerFunc func(ctx Context)
@@ -38,6 +47,14 @@ type context struct {
 	paramValues map[string]string
 	handlers    handlersChain
 	index       int
+	route       string
+	router      *router
+	goctx       gocontext.Context
+	cancel      gocontext.CancelFunc
+	unwatch     func() // stops watching the connection, see Ctx
+	detached    bool   // a handler goroutine outlived the request, see Timeout
+	client      *forwarded
+	clientOnce  sync.Once
 }
 
 func (ctx *context) Next() {
@@ -51,6 +68,84 @@ func (ctx *context) Param(key string) string {
 	return ctx.paramValues[key]
 }
 
+// Route returns the path of the matched route, e.g. "/users/:id"
+func (ctx *context) Route() string {
+	return ctx.route
+}
+
+// RequestID returns the id given to the request by the RequestID middleware
+func (ctx *context) RequestID() string {
+	id, _ := ctx.GetLocal(localRequestID).(string)
+	return id
+}
+
+// IP returns the client address, behind trusted proxies it is taken from the
+// Forwarded, X-Forwarded-For or X-Real-IP headers, see Settings.TrustedProxies
+func (ctx *context) IP() string {
+	return ctx.forwarded().ips[0].String()
+}
+
+// IPs returns the client address followed by the trusted proxies the request
+// went through, the last one is the peer of the connection
+func (ctx *context) IPs() []string {
+	ips := ctx.forwarded().ips
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	return addrs
+}
+
+// Scheme returns "http" or "https" as requested by the client, behind trusted
+// proxies it is taken from the Forwarded or X-Forwarded-Proto headers
+func (ctx *context) Scheme() string {
+	if scheme := ctx.forwarded().scheme; scheme == "http" || scheme == "https" {
+		return scheme
+	}
+	if ctx.requestCtx.IsTLS() {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host requested by the client, behind trusted proxies it is
+// taken from the Forwarded or X-Forwarded-Host headers
+func (ctx *context) Host() string {
+	if host := ctx.forwarded().host; host != "" {
+		return host
+	}
+	return GetString(ctx.requestCtx.Host())
+}
+
+// Ctx returns the context.Context of the request, it is cancelled when the
+// handlers returned, the server stops, a Timeout middleware deadline passes or
+// the client closes the connection. Closed connections are noticed on unix
+// systems only, and not once the client sent bytes of a pipelined request.
+func (ctx *context) Ctx() gocontext.Context {
+	if ctx.goctx == nil {
+		parent := gocontext.Background()
+		if ctx.router != nil && ctx.router.baseCtx != nil {
+			parent = ctx.router.baseCtx
+		}
+		ctx.goctx, ctx.cancel = gocontext.WithCancel(parent)
+
+		if ctx.requestCtx != nil {
+			if conn := ctx.requestCtx.Conn(); conn != nil {
+				ctx.unwatch = watchConn(conn, ctx.cancel)
+			}
+		}
+	}
+	return ctx.goctx
+}
+
+// settings returns the settings of the server handling the request
+func (ctx *context) settings() *Settings {
+	if ctx.router == nil || ctx.router.settings == nil {
+		return &Settings{}
+	}
+	return ctx.router.settings
+}
+
 func (ctx *context) Context() *fasthttp.RequestCtx {
 	return ctx.requestCtx
 }
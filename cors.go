@@ -0,0 +1,187 @@
+package godzilla
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS headers
+const (
+	HeaderOrigin                        = "Origin"
+	HeaderVary                          = "Vary"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+)
+
+// CORSConfig holds the CORS middleware settings
+type CORSConfig struct {
+	// Allowed origins, "*" allows any origin and "https://*.example.com" any
+	// subdomain of example.com
+	AllowOrigins []string // default ["*"]
+
+	// Decides whether an origin is allowed, used when no AllowOrigins entry matches
+	AllowOriginFunc func(origin string) bool // default nil
+
+	// Methods allowed in preflight requests
+	AllowMethods []string // default the methods registered for the requested path
+
+	// Headers allowed in preflight requests
+	AllowHeaders []string // default the requested headers
+
+	// Response headers scripts are allowed to read
+	ExposeHeaders []string // default nil
+
+	// Allow requests with cookies and http authentication, it requires
+	// AllowOrigins without "*" or AllowOriginFunc
+	AllowCredentials bool // default false
+
+	// How long preflight responses can be cached
+	MaxAge time.Duration // default 0 (not sent)
+}
+
+// CORS returns a middleware implementing cross-origin resource sharing.
+// Preflight requests are answered by the middleware, for routes without an
+// OPTIONS handler Settings.HandleOPTIONS must be set and the middleware must
+// be registered with Use. The router runs the global middlewares for these
+// requests like for any other, CORS should be registered before the ones
+// rejecting requests without credentials, which preflight requests never
+// carry. CORS panics when credentials are allowed for a wildcard origin,
+// which would reflect the origin of any site.
+func CORS(config ...*CORSConfig) handlerFunc {
+	cfg := &CORSConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	origins := cfg.AllowOrigins
+	if len(origins) == 0 && cfg.AllowOriginFunc == nil {
+		origins = []string{"*"}
+	}
+
+	anyOrigin := false
+	for _, origin := range origins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+	}
+
+	if anyOrigin && cfg.AllowCredentials {
+		panic("cors: AllowCredentials requires explicit AllowOrigins or AllowOriginFunc")
+	}
+
+	allowMethods := strings.Join(cfg.AllowMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+
+	allowed := func(origin string) bool {
+		for _, pattern := range origins {
+			if matchOrigin(pattern, origin) {
+				return true
+			}
+		}
+		return cfg.AllowOriginFunc != nil && cfg.AllowOriginFunc(origin)
+	}
+
+	return func(ctx Context) {
+		origin := ctx.Get(HeaderOrigin)
+		fctx := ctx.Context()
+		preflight := string(fctx.Method()) == MethodOptions && ctx.Get(HeaderAccessControlRequestMethod) != ""
+
+		// the response depends on the origin unless any origin gets "*"
+		if !anyOrigin || cfg.AllowCredentials {
+			fctx.Response.Header.Add(HeaderVary, HeaderOrigin)
+		}
+
+		if origin == "" || !allowed(origin) {
+			ctx.Next()
+			return
+		}
+
+		if anyOrigin && !cfg.AllowCredentials {
+			ctx.Set(HeaderAccessControlAllowOrigin, "*")
+		} else {
+			ctx.Set(HeaderAccessControlAllowOrigin, origin)
+		}
+
+		if cfg.AllowCredentials {
+			ctx.Set(HeaderAccessControlAllowCredentials, "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				ctx.Set(HeaderAccessControlExposeHeaders, exposeHeaders)
+			}
+			ctx.Next()
+			return
+		}
+
+		fctx.Response.Header.Add(HeaderVary, HeaderAccessControlRequestMethod)
+		fctx.Response.Header.Add(HeaderVary, HeaderAccessControlRequestHeaders)
+
+		methods := allowMethods
+		if methods == "" {
+			methods = allowedMethods(ctx)
+		}
+		if methods != "" {
+			ctx.Set(HeaderAccessControlAllowMethods, methods)
+		}
+
+		headers := allowHeaders
+		if headers == "" {
+			headers = ctx.Get(HeaderAccessControlRequestHeaders)
+		}
+		if headers != "" {
+			ctx.Set(HeaderAccessControlAllowHeaders, headers)
+		}
+
+		if cfg.MaxAge > 0 {
+			ctx.Set(HeaderAccessControlMaxAge, maxAge)
+		}
+
+		ctx.Status(StatusNoContent)
+	}
+}
+
+// allowedMethods returns the methods registered for the requested path, as
+// computed by the router for the Allow header
+func allowedMethods(ctx Context) string {
+	c, ok := ctx.(*context)
+	if !ok || c.router == nil {
+		return ""
+	}
+
+	path, _ := routingPath(c)
+
+	// matching overwrites params, the preflight response does not use them
+	return c.router.allowed(MethodOptions, path, &context{paramValues: make(map[string]string)})
+}
+
+// matchOrigin reports whether origin matches pattern, a "*" in pattern
+// matches any non-empty part of origin, e.g. "https://*.example.com"
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return false
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) ||
+		!strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	// the wildcard must not swallow the scheme or the port
+	return !strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:")
+}
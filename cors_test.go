@@ -0,0 +1,156 @@
+package godzilla
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestCORS tests simple and preflight cross-origin requests
+func TestCORS(t *testing.T) {
+	gz := setupGodzilla(&Settings{HandleOPTIONS: true})
+	gz.Use(CORS(&CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:3000" },
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	gz.Get("/users/:id", pingHandler)
+	gz.Put("/users/:id", pingHandler)
+	startGodzilla(gz)
+
+	testCases := []struct {
+		method     string
+		origin     string
+		preflight  bool
+		statusCode int
+		headers    map[string]string
+	}{
+		{method: MethodGet, origin: "https://app.example.com", statusCode: StatusOK, headers: map[string]string{
+			HeaderAccessControlAllowOrigin:      "https://app.example.com",
+			HeaderAccessControlAllowCredentials: "true",
+			HeaderAccessControlExposeHeaders:    "X-Total",
+			HeaderVary:                          HeaderOrigin,
+		}},
+		{method: MethodGet, origin: "https://api.example.org", statusCode: StatusOK, headers: map[string]string{HeaderAccessControlAllowOrigin: "https://api.example.org"}},
+		{method: MethodGet, origin: "http://localhost:3000", statusCode: StatusOK, headers: map[string]string{HeaderAccessControlAllowOrigin: "http://localhost:3000"}},
+		{method: MethodGet, origin: "https://evil.com", statusCode: StatusOK, headers: map[string]string{HeaderAccessControlAllowOrigin: ""}},
+		{method: MethodGet, origin: "https://example.org", statusCode: StatusOK, headers: map[string]string{HeaderAccessControlAllowOrigin: ""}},
+		{method: MethodOptions, origin: "https://app.example.com", preflight: true, statusCode: StatusNoContent, headers: map[string]string{
+			HeaderAccessControlAllowOrigin:  "https://app.example.com",
+			HeaderAccessControlAllowHeaders: "Content-Type",
+			HeaderAccessControlMaxAge:       "600",
+		}},
+		{method: MethodOptions, origin: "https://evil.com", preflight: true, statusCode: StatusOK, headers: map[string]string{
+			HeaderAccessControlAllowOrigin:  "",
+			HeaderAccessControlAllowMethods: "",
+		}},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(tc.method, "/users/42", nil)
+		req.Header.Set(HeaderOrigin, tc.origin)
+		if tc.preflight {
+			req.Header.Set(HeaderAccessControlRequestMethod, MethodPut)
+			req.Header.Set(HeaderAccessControlRequestHeaders, "Content-Type")
+		}
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.method, tc.origin, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s(%s): returned %d expected %d", tc.method, tc.origin, response.StatusCode, tc.statusCode)
+		}
+
+		for key, value := range tc.headers {
+			if actual := response.Header.Get(key); actual != value {
+				t.Fatalf("%s(%s): header %s returned %q expected %q", tc.method, tc.origin, key, actual, value)
+			}
+		}
+
+		if tc.preflight && tc.statusCode == StatusNoContent {
+			methods := response.Header.Get(HeaderAccessControlAllowMethods)
+			if methods != "GET, PUT, OPTIONS" && methods != "PUT, GET, OPTIONS" {
+				t.Fatalf("%s(%s): returned allowed methods %q", tc.method, tc.origin, methods)
+			}
+		}
+	}
+}
+
+// TestCORSPreflight tests preflight requests answered by the router run the
+// global middlewares, denied clients get no CORS response
+func TestCORSPreflight(t *testing.T) {
+	filter, _ := NewIPFilter(&IPFilterConfig{Deny: []string{"203.0.113.0/24"}})
+
+	var logged int
+	gz := setupGodzilla(&Settings{HandleOPTIONS: true, TrustedProxies: []string{"127.0.0.1"}})
+	gz.Use(func(ctx Context) {
+		logged++
+		ctx.Next()
+	})
+	gz.Use(filter.Handler())
+	gz.Use(CORS(&CORSConfig{AllowOrigins: []string{"https://app.example.com"}}))
+	gz.Use(func(ctx Context) {
+		if ctx.Get("Authorization") == "" {
+			ctx.Status(StatusUnauthorized)
+			return
+		}
+		ctx.Next()
+	})
+	gz.Get("/users/:id", pingHandler)
+	startGodzilla(gz)
+
+	testCases := []struct {
+		ip         string
+		statusCode int
+		origin     string
+	}{
+		{ip: "198.51.100.1", statusCode: StatusNoContent, origin: "https://app.example.com"},
+		{ip: "203.0.113.5", statusCode: StatusForbidden, origin: ""},
+	}
+
+	for i, tc := range testCases {
+		req, _ := http.NewRequest(MethodOptions, "/users/42", nil)
+		req.Header.Set(HeaderOrigin, "https://app.example.com")
+		req.Header.Set(HeaderAccessControlRequestMethod, MethodGet)
+		req.Header.Set(HeaderXForwardedFor, tc.ip)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != tc.statusCode || response.Header.Get(HeaderAccessControlAllowOrigin) != tc.origin {
+			t.Fatalf("%s: returned %d %q expected %d %q", tc.ip, response.StatusCode, response.Header.Get(HeaderAccessControlAllowOrigin), tc.statusCode, tc.origin)
+		}
+		if logged != i+1 {
+			t.Fatalf("%s: middlewares before CORS ran %d times expected %d", tc.ip, logged, i+1)
+		}
+	}
+}
+
+// TestCORSCredentials tests credentials are refused for wildcard origins
+func TestCORSCredentials(t *testing.T) {
+	testCases := []struct {
+		config *CORSConfig
+		panics bool
+	}{
+		{config: &CORSConfig{AllowCredentials: true}, panics: true},
+		{config: &CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}, panics: true},
+		{config: &CORSConfig{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true}},
+		{config: &CORSConfig{AllowOriginFunc: func(string) bool { return true }, AllowCredentials: true}},
+	}
+
+	for i, tc := range testCases {
+		func() {
+			defer func() {
+				if panicked := recover() != nil; panicked != tc.panics {
+					t.Fatalf("%d: panicked %t expected %t", i, panicked, tc.panics)
+				}
+			}()
+			CORS(tc.config)
+		}()
+	}
+}
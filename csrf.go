@@ -0,0 +1,299 @@
+package godzilla
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	// ErrCSRFToken is returned when an unsafe request has a missing or wrong token
+	ErrCSRFToken = errors.New("csrf: missing or invalid token")
+
+	// ErrCSRFOrigin is returned when an unsafe request comes from another origin
+	ErrCSRFOrigin = errors.New("csrf: origin not allowed")
+)
+
+// CSRFConfig holds the csrf middleware settings. Tokens are double-submit
+// cookies by default, setting SessionID switches to synchronizer tokens kept
+// in Store.
+type CSRFConfig struct {
+	// Header unsafe requests send the token in
+	HeaderName string // default "X-CSRF-Token"
+
+	// Form field unsafe requests send the token in, checked when the header is missing
+	FormField string // default "_csrf"
+
+	// Locals key of the token, e.g. for templates
+	ContextKey string // default "csrf"
+
+	// Cookie holding the token of the double-submit pattern
+	CookieName     string                  // default "_csrf"
+	CookiePath     string                  // default "/"
+	CookieDomain   string                  // default ""
+	CookieSecure   bool                    // default false
+	CookieHTTPOnly bool                    // default false, scripts may need to read the token
+	CookieSameSite fasthttp.CookieSameSite // default fasthttp.CookieSameSiteLaxMode
+
+	// Lifetime of tokens
+	Expiration time.Duration // default 24 hours
+
+	// Returns the session of a request, enables the synchronizer token pattern,
+	// requests without session ("") use the double-submit cookie
+	SessionID func(ctx Context) string // default nil
+
+	// Storage of synchronizer tokens keyed by session
+	Store CSRFStore // default in-memory
+
+	// Origins allowed besides the requested host, e.g. "https://app.example.com"
+	TrustedOrigins []string // default nil
+
+	// Routes that are not checked, keyed by route path
+	Exempt []string // default nil
+
+	// Answers rejected requests with ErrCSRFToken or ErrCSRFOrigin
+	ErrorHandler func(ctx Context, err error) // default 403 Forbidden
+}
+
+// CSRFStore keeps synchronizer tokens, implementations must be safe for
+// concurrent use
+type CSRFStore interface {
+	// Get returns the token of session, an empty token when there is none
+	Get(session string) (string, error)
+
+	// Set stores the token of session until expiration passed
+	Set(session, token string, expiration time.Duration) error
+}
+
+// CSRF returns a middleware protecting unsafe requests against cross-site
+// request forgery, the token is available with CSRFToken
+func CSRF(config ...*CSRFConfig) handlerFunc {
+	cfg := &CSRFConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	headerName := stringOr(cfg.HeaderName, "X-CSRF-Token")
+	formField := stringOr(cfg.FormField, "_csrf")
+	contextKey := stringOr(cfg.ContextKey, "csrf")
+	cookieName := stringOr(cfg.CookieName, "_csrf")
+	cookiePath := stringOr(cfg.CookiePath, "/")
+
+	expiration := cfg.Expiration
+	if expiration <= 0 {
+		expiration = 24 * time.Hour
+	}
+
+	sameSite := cfg.CookieSameSite
+	if sameSite == fasthttp.CookieSameSiteDisabled {
+		sameSite = fasthttp.CookieSameSiteLaxMode
+	}
+
+	store := cfg.Store
+	if store == nil && cfg.SessionID != nil {
+		store = newCSRFMemoryStore()
+	}
+
+	exempt := make(map[string]bool, len(cfg.Exempt))
+	for _, route := range cfg.Exempt {
+		exempt[route] = true
+	}
+
+	errorHandler := cfg.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(ctx Context, err error) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusForbidden),
+				fasthttp.StatusForbidden)
+		}
+	}
+
+	// load returns the current token and where it is kept, clients without
+	// session, e.g. before logging in, use the double-submit cookie so they
+	// never share a token kept under an empty session
+	load := func(ctx Context) (token, session string, err error) {
+		if cfg.SessionID != nil {
+			session = cfg.SessionID(ctx)
+		}
+
+		if session == "" {
+			return string(ctx.Context().Request.Header.Cookie(cookieName)), "", nil
+		}
+
+		token, err = store.Get(session)
+		return token, session, err
+	}
+
+	save := func(ctx Context, token, session string) error {
+		if session != "" {
+			return store.Set(session, token, expiration)
+		}
+
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+
+		cookie.SetKey(cookieName)
+		cookie.SetValue(token)
+		cookie.SetPath(cookiePath)
+		cookie.SetDomain(cfg.CookieDomain)
+		cookie.SetMaxAge(int(expiration / time.Second))
+		cookie.SetSecure(cfg.CookieSecure)
+		cookie.SetHTTPOnly(cfg.CookieHTTPOnly)
+		cookie.SetSameSite(sameSite)
+		ctx.Context().Response.Header.SetCookie(cookie)
+		return nil
+	}
+
+	return func(ctx Context) {
+		token, session, err := load(ctx)
+		if err != nil {
+			requestLogger(ctx).Error("loading csrf token failed", "error", err)
+			errorHandler(ctx, ErrCSRFToken)
+			return
+		}
+
+		expected := token
+		if token == "" {
+			token = newCSRFToken()
+			if err := save(ctx, token, session); err != nil {
+				requestLogger(ctx).Error("saving csrf token failed", "error", err)
+				errorHandler(ctx, ErrCSRFToken)
+				return
+			}
+		}
+		ctx.SetLocal(contextKey, token)
+		ctx.SetLocal(localCSRFToken, token)
+
+		if safeMethod(string(ctx.Context().Method())) || exempt[ctx.Route()] {
+			ctx.Next()
+			return
+		}
+
+		if !sameOrigin(ctx, cfg.TrustedOrigins) {
+			errorHandler(ctx, ErrCSRFOrigin)
+			return
+		}
+
+		submitted := ctx.Get(headerName)
+		if submitted == "" {
+			submitted = string(ctx.Context().FormValue(formField))
+		}
+
+		if expected == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(expected)) != 1 {
+			errorHandler(ctx, ErrCSRFToken)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+const localCSRFToken = "godzilla.csrf.token"
+
+// CSRFToken returns the token of the request, to be sent back by forms and
+// scripts in unsafe requests
+func CSRFToken(ctx Context) string {
+	token, _ := ctx.GetLocal(localCSRFToken).(string)
+	return token
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("csrf: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeMethod reports whether method is safe as defined by RFC 7231
+func safeMethod(method string) bool {
+	return method == MethodGet || method == MethodHead || method == MethodOptions || method == MethodTrace
+}
+
+// sameOrigin checks the Origin header, or the Referer when there is no Origin,
+// against the requested host and trusted origins. Requests with neither are
+// left to the token check.
+func sameOrigin(ctx Context, trusted []string) bool {
+	origin := ctx.Get(HeaderOrigin)
+	if origin == "" {
+		referer := ctx.Get("Referer")
+		if referer == "" {
+			return true
+		}
+
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	for _, o := range trusted {
+		if matchOrigin(o, origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == ctx.Host()
+}
+
+func stringOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// csrfMemoryStore is the default in-memory CSRFStore
+type csrfMemoryStore struct {
+	mutex     sync.Mutex
+	tokens    map[string]csrfEntry
+	lastSweep time.Time
+}
+
+type csrfEntry struct {
+	token   string
+	expires time.Time
+}
+
+func newCSRFMemoryStore() *csrfMemoryStore {
+	return &csrfMemoryStore{tokens: make(map[string]csrfEntry), lastSweep: time.Now()}
+}
+
+func (s *csrfMemoryStore) Get(session string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.tokens[session]
+	if !ok || time.Now().After(entry.expires) {
+		return "", nil
+	}
+	return entry.token, nil
+}
+
+func (s *csrfMemoryStore) Set(session, token string, expiration time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	// session may alias a request buffer, see GetString
+	s.tokens[string([]byte(session))] = csrfEntry{token: token, expires: now.Add(expiration)}
+
+	// drop expired tokens at most once a minute
+	if now.Sub(s.lastSweep) > time.Minute {
+		for key, entry := range s.tokens {
+			if now.After(entry.expires) {
+				delete(s.tokens, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
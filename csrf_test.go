@@ -0,0 +1,180 @@
+package godzilla
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCSRFDoubleSubmit tests the double-submit cookie pattern
+func TestCSRFDoubleSubmit(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(CSRF(&CSRFConfig{
+		TrustedOrigins: []string{"https://app.example.com"},
+		Exempt:         []string{"/webhook"},
+	}))
+	gz.Get("/form", func(ctx Context) {
+		ctx.SendString(CSRFToken(ctx))
+	})
+	gz.Post("/form", pingHandler)
+	gz.Post("/webhook", pingHandler)
+	startGodzilla(gz)
+
+	// a safe request issues the token
+	req, _ := http.NewRequest(MethodGet, "/form", nil)
+	response, err := makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(response.Body)
+	token := string(body)
+
+	cookies := response.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "_csrf" || cookies[0].Value != token {
+		t.Fatalf("returned cookies %v expected _csrf=%s", cookies, token)
+	}
+
+	testCases := []struct {
+		name       string
+		path       string
+		cookie     string
+		header     string
+		form       string
+		origin     string
+		statusCode int
+	}{
+		{name: "header", path: "/form", cookie: token, header: token, statusCode: StatusOK},
+		{name: "form field", path: "/form", cookie: token, form: token, statusCode: StatusOK},
+		{name: "trusted origin", path: "/form", cookie: token, header: token, origin: "https://app.example.com", statusCode: StatusOK},
+		{name: "same host", path: "/form", cookie: token, header: token, origin: "http://example.com", statusCode: StatusOK},
+		{name: "other origin", path: "/form", cookie: token, header: token, origin: "https://evil.com", statusCode: StatusForbidden},
+		{name: "missing token", path: "/form", cookie: token, statusCode: StatusForbidden},
+		{name: "wrong token", path: "/form", cookie: token, header: "guess", statusCode: StatusForbidden},
+		{name: "missing cookie", path: "/form", header: token, statusCode: StatusForbidden},
+		{name: "exempt route", path: "/webhook", statusCode: StatusOK},
+	}
+
+	for _, tc := range testCases {
+		var req *http.Request
+		if tc.form != "" {
+			form := url.Values{"_csrf": {tc.form}}.Encode()
+			req, _ = http.NewRequest(MethodPost, "http://example.com"+tc.path, strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Content-Length", strconv.Itoa(len(form)))
+		} else {
+			req, _ = http.NewRequest(MethodPost, "http://example.com"+tc.path, nil)
+		}
+
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "_csrf", Value: tc.cookie})
+		}
+		if tc.header != "" {
+			req.Header.Set("X-CSRF-Token", tc.header)
+		}
+		if tc.origin != "" {
+			req.Header.Set(HeaderOrigin, tc.origin)
+		}
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s: returned %d expected %d", tc.name, response.StatusCode, tc.statusCode)
+		}
+	}
+}
+
+// TestCSRFSynchronizer tests tokens kept server-side per session
+func TestCSRFSynchronizer(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(CSRF(&CSRFConfig{
+		SessionID: func(ctx Context) string { return ctx.Get("X-Session") },
+	}))
+	gz.Get("/form", func(ctx Context) {
+		ctx.SendString(ctx.GetLocal("csrf").(string))
+	})
+	gz.Post("/form", pingHandler)
+	startGodzilla(gz)
+
+	tokens := make(map[string]string)
+	for _, session := range []string{"a", "b"} {
+		req, _ := http.NewRequest(MethodGet, "/form", nil)
+		req.Header.Set("X-Session", session)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Cookies()) != 0 {
+			t.Fatalf("session %s: returned cookies %v", session, response.Cookies())
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		tokens[session] = string(body)
+	}
+
+	testCases := []struct {
+		session    string
+		token      string
+		statusCode int
+	}{
+		{session: "a", token: tokens["a"], statusCode: StatusOK},
+		{session: "b", token: tokens["b"], statusCode: StatusOK},
+		{session: "b", token: tokens["a"], statusCode: StatusForbidden},
+		{session: "c", token: tokens["a"], statusCode: StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodPost, "/form", nil)
+		req.Header.Set("X-Session", tc.session)
+		req.Header.Set("X-CSRF-Token", tc.token)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("session %s: %s", tc.session, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("session %s: returned %d expected %d", tc.session, response.StatusCode, tc.statusCode)
+		}
+	}
+
+	// clients without session fall back to the double-submit cookie instead
+	// of sharing the token of the empty session
+	anonymous := make([]*http.Cookie, 2)
+	for i := range anonymous {
+		req, _ := http.NewRequest(MethodGet, "/form", nil)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Cookies()) != 1 {
+			t.Fatalf("anonymous %d: returned cookies %v expected the csrf cookie", i, response.Cookies())
+		}
+		anonymous[i] = response.Cookies()[0]
+	}
+	if anonymous[0].Value == anonymous[1].Value {
+		t.Fatal("anonymous clients share a token")
+	}
+
+	for i, cookie := range []*http.Cookie{anonymous[0], anonymous[1]} {
+		req, _ := http.NewRequest(MethodPost, "/form", nil)
+		req.AddCookie(cookie)
+		req.Header.Set("X-CSRF-Token", anonymous[0].Value)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []int{StatusOK, StatusForbidden}[i]; response.StatusCode != expected {
+			t.Fatalf("anonymous %d: returned %d expected %d", i, response.StatusCode, expected)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package godzilla
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Conditional request headers
+const (
+	HeaderETag              = "ETag"
+	HeaderLastModified      = "Last-Modified"
+	HeaderIfMatch           = "If-Match"
+	HeaderIfNoneMatch       = "If-None-Match"
+	HeaderIfUnmodifiedSince = "If-Unmodified-Since"
+)
+
+// ETagConfig holds the etag middleware settings
+type ETagConfig struct {
+	// Generate weak validators, e.g. when responses are also compressed
+	Weak bool // default false
+
+	// Returns the validators of the current representation, checked against
+	// the preconditions of unsafe requests, ok is false when it does not exist
+	Current func(ctx Context) (etag string, lastModified time.Time, ok bool) // default nil (preconditions are not checked)
+
+	// Skip etags for some requests
+	Skip func(ctx Context) bool // default nil
+}
+
+// ETag returns a middleware setting an ETag computed from the body of 200
+// responses, unless the handlers set one, and answering GET and HEAD requests
+// with 304 when If-None-Match matches. When ETagConfig.Current is set, unsafe
+// requests with If-Match, If-None-Match or If-Unmodified-Since preconditions
+// that fail are answered with 412 before the handlers run.
+func ETag(config ...*ETagConfig) handlerFunc {
+	cfg := &ETagConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(ctx Context) {
+		if cfg.Skip != nil && cfg.Skip(ctx) {
+			ctx.Next()
+			return
+		}
+
+		fctx := ctx.Context()
+		method := GetString(fctx.Method())
+
+		if !safeMethod(method) {
+			if cfg.Current == nil {
+				ctx.Next()
+				return
+			}
+
+			ifMatch, ifNoneMatch := ctx.Get(HeaderIfMatch), ctx.Get(HeaderIfNoneMatch)
+			ifUnmodifiedSince := ctx.Get(HeaderIfUnmodifiedSince)
+			if ifMatch != "" || ifNoneMatch != "" || ifUnmodifiedSince != "" {
+				etag, lastModified, exists := cfg.Current(ctx)
+				if !preconditionsHold(ifMatch, ifNoneMatch, ifUnmodifiedSince, etag, lastModified, exists) {
+					fctx.Error(fasthttp.StatusMessage(fasthttp.StatusPreconditionFailed),
+						fasthttp.StatusPreconditionFailed)
+					return
+				}
+			}
+			ctx.Next()
+			return
+		}
+
+		ctx.Next()
+
+		resp := &fctx.Response
+		if resp.StatusCode() != StatusOK || resp.IsBodyStream() {
+			return
+		}
+
+		etag := GetString(resp.Header.Peek(HeaderETag))
+		if etag == "" {
+			etag = computeETag(resp.Body(), cfg.Weak)
+			resp.Header.Set(HeaderETag, etag)
+		}
+
+		if (method == MethodGet || method == MethodHead) && matchETag(ctx.Get(HeaderIfNoneMatch), etag, false) {
+			resp.SetStatusCode(StatusNotModified)
+			resp.ResetBody()
+		}
+	}
+}
+
+// preconditionsHold evaluates the preconditions of an unsafe request in the
+// order of RFC 7232, section 6
+func preconditionsHold(ifMatch, ifNoneMatch, ifUnmodifiedSince, etag string, lastModified time.Time, exists bool) bool {
+	if ifMatch != "" {
+		if !exists || !matchETag(ifMatch, etag, true) {
+			return false
+		}
+	} else if ifUnmodifiedSince != "" && exists && !lastModified.IsZero() {
+		since, err := fasthttp.ParseHTTPDate([]byte(ifUnmodifiedSince))
+		if err == nil && lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	if ifNoneMatch != "" && exists && matchETag(ifNoneMatch, etag, false) {
+		return false
+	}
+	return true
+}
+
+// matchETag reports whether etag is in list, "*" matches any etag. Strong
+// comparison never matches weak etags.
+func matchETag(list, etag string, strong bool) bool {
+	if list == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// computeETag returns a quoted digest of body
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
@@ -0,0 +1,193 @@
+package godzilla
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TestETag tests etags and 304 responses to If-None-Match
+func TestETag(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(ETag())
+	gz.Get("/json", func(ctx Context) {
+		ctx.SendJSON(map[string]string{"name": "godzilla"})
+	})
+	gz.Head("/json", func(ctx Context) {
+		ctx.SendJSON(map[string]string{"name": "godzilla"})
+	})
+	gz.Get("/custom", func(ctx Context) {
+		ctx.Set(HeaderETag, `"v1"`)
+		ctx.SendString("custom")
+	})
+	gz.Get("/error", func(ctx Context) {
+		ctx.Status(StatusNotFound).SendString("error")
+	})
+	startGodzilla(gz)
+
+	etag := computeETag([]byte(`{"name":"godzilla"}`), false)
+
+	testCases := []struct {
+		method      string
+		path        string
+		ifNoneMatch string
+		status      int
+		etag        string
+		body        string
+	}{
+		{method: MethodGet, path: "/json", status: StatusOK, etag: etag, body: `{"name":"godzilla"}`},
+		{method: MethodGet, path: "/json", ifNoneMatch: etag, status: StatusNotModified, etag: etag},
+		{method: MethodGet, path: "/json", ifNoneMatch: `"other", W/` + etag, status: StatusNotModified, etag: etag},
+		{method: MethodGet, path: "/json", ifNoneMatch: "*", status: StatusNotModified, etag: etag},
+		{method: MethodHead, path: "/json", ifNoneMatch: etag, status: StatusNotModified, etag: etag},
+		{method: MethodGet, path: "/json", ifNoneMatch: `"other"`, status: StatusOK, etag: etag, body: `{"name":"godzilla"}`},
+		{method: MethodGet, path: "/custom", ifNoneMatch: `"v1"`, status: StatusNotModified, etag: `"v1"`},
+		{method: MethodGet, path: "/error", ifNoneMatch: "*", status: StatusNotFound, body: "error"},
+	}
+
+	for i, tc := range testCases {
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		if tc.ifNoneMatch != "" {
+			req.Header.Set(HeaderIfNoneMatch, tc.ifNoneMatch)
+		}
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		if response.StatusCode != tc.status || response.Header.Get(HeaderETag) != tc.etag {
+			t.Fatalf("%d %s %s: returned %d %s expected %d %s", i, tc.method, tc.path,
+				response.StatusCode, response.Header.Get(HeaderETag), tc.status, tc.etag)
+		}
+		if tc.method == MethodGet && string(body) != tc.body {
+			t.Fatalf("%d %s %s: returned body %q expected %q", i, tc.method, tc.path, body, tc.body)
+		}
+	}
+}
+
+// TestETagWeak tests weak etags
+func TestETagWeak(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(ETag(&ETagConfig{Weak: true}))
+	gz.Get("/", pingHandler)
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodGet, "/", nil)
+	response, err := makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := response.Header.Get(HeaderETag)
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("returned etag %s expected a weak etag", etag)
+	}
+
+	req, _ = http.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(HeaderIfNoneMatch, strings.TrimPrefix(etag, "W/"))
+	response, err = makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != StatusNotModified {
+		t.Fatalf("returned %d expected %d", response.StatusCode, StatusNotModified)
+	}
+}
+
+// TestETagPreconditions tests If-Match, If-None-Match and If-Unmodified-Since
+// on unsafe requests
+func TestETagPreconditions(t *testing.T) {
+	modified := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	document := "v1"
+
+	// the validators of the stored document, no GET request is made
+	gz := setupGodzilla()
+	gz.Use(ETag(&ETagConfig{
+		Current: func(ctx Context) (string, time.Time, bool) {
+			if ctx.Route() != "/document" {
+				return "", time.Time{}, false
+			}
+			return computeETag([]byte(`{"document":"`+document+`"}`), false), modified, true
+		},
+	}))
+	gz.Get("/document", func(ctx Context) {
+		ctx.Set(HeaderLastModified, string(fasthttp.AppendHTTPDate(nil, modified)))
+		ctx.SendJSON(map[string]string{"document": document})
+	})
+	gz.Put("/document", func(ctx Context) {
+		document = string(ctx.Context().PostBody())
+		ctx.Status(StatusNoContent)
+	})
+	gz.Put("/missing", func(ctx Context) {
+		ctx.Status(StatusCreated)
+	})
+	startGodzilla(gz)
+
+	current := computeETag([]byte(`{"document":"v1"}`), false)
+
+	testCases := []struct {
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{path: "/document", headers: map[string]string{HeaderIfMatch: `"stale"`}, status: StatusPreconditionFailed},
+		{path: "/document", headers: map[string]string{HeaderIfMatch: "W/" + current}, status: StatusPreconditionFailed},
+		{path: "/document", headers: map[string]string{HeaderIfMatch: `"stale", ` + current}, status: StatusNoContent},
+		// the document changed, the etag sent before is stale now
+		{path: "/document", headers: map[string]string{HeaderIfMatch: current}, status: StatusPreconditionFailed},
+		{path: "/document", headers: map[string]string{HeaderIfMatch: "*"}, status: StatusNoContent},
+		{path: "/document", headers: map[string]string{HeaderIfNoneMatch: "*"}, status: StatusPreconditionFailed},
+		{path: "/document", headers: map[string]string{HeaderIfUnmodifiedSince: "Mon, 31 May 2021 12:00:00 GMT"}, status: StatusPreconditionFailed},
+		{path: "/document", headers: map[string]string{HeaderIfUnmodifiedSince: "Tue, 01 Jun 2021 12:00:00 GMT"}, status: StatusNoContent},
+		{path: "/document", status: StatusNoContent},
+		{path: "/missing", headers: map[string]string{HeaderIfMatch: "*"}, status: StatusPreconditionFailed},
+		{path: "/missing", headers: map[string]string{HeaderIfNoneMatch: "*"}, status: StatusCreated},
+	}
+
+	for i, tc := range testCases {
+		body := "v" + strconv.Itoa(i+2)
+		req, _ := http.NewRequest(MethodPut, tc.path, strings.NewReader(body))
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		for key, value := range tc.headers {
+			req.Header.Set(key, value)
+		}
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != tc.status {
+			t.Fatalf("%d PUT %s %v: returned %d expected %d", i, tc.path, tc.headers, response.StatusCode, tc.status)
+		}
+	}
+}
+
+// TestETagPreconditionsWithoutCurrent tests preconditions are not checked
+// without ETagConfig.Current
+func TestETagPreconditionsWithoutCurrent(t *testing.T) {
+	var gets int
+	gz := setupGodzilla()
+	gz.Use(ETag())
+	gz.Get("/document", func(ctx Context) {
+		gets++
+		ctx.SendString("v1")
+	})
+	gz.Put("/document", func(ctx Context) {
+		ctx.Status(StatusNoContent)
+	})
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodPut, "/document", nil)
+	req.Header.Set(HeaderIfMatch, `"stale"`)
+	response, err := makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != StatusNoContent || gets != 0 {
+		t.Fatalf("returned %d and ran %d GET handlers expected %d and none", response.StatusCode, gets, StatusNoContent)
+	}
+}
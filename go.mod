@@ -19,8 +19,8 @@ require (
 )
 
 require (
-	github.com/andybalholm/brotli v1.0.4 // indirect
-	github.com/klauspost/compress v1.13.6 // indirect
+	github.com/andybalholm/brotli v1.0.4
+	github.com/klauspost/compress v1.13.6
 	github.com/livebud/bud v0.2.8
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	go.kuoruan.net/v8go-polyfills v0.5.1-0.20220727011656-c74c5b408ebd
@@ -1,13 +1,16 @@
 package godzilla
 
 import (
-	"log"
+	gocontext "context"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/godzillaframework/godzilla/container/js"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/prefork"
 )
@@ -16,8 +19,7 @@ const (
 	version = "2.1.0"
 	name    = "Godzilla Framework"
 
-	banner = `
-Server Running on %s`
+	banner = "Server Running on %s"
 )
 
 const (
@@ -134,6 +136,15 @@ type Godzilla interface {
 	Static(prefix, root string)
 	NotFound(handlers ...handlerFunc)
 	Use(middlewares ...handlerFunc)
+	OnStop(hooks ...func() error)
+	GetJS(path, script string) *Route
+	PostJS(path, script string) *Route
+	PutJS(path, script string) *Route
+	PatchJS(path, script string) *Route
+	DeleteJS(path, script string) *Route
+	UseJS(script string)
+	JS(script string) handlerFunc
+	Handler() fasthttp.RequestHandler
 }
 
 type godzilla struct {
@@ -143,6 +154,7 @@ type godzilla struct {
 	address          string // server address
 	middlewares      handlersChain
 	settings         *Settings
+	stopHooks        []func() error
 }
 
 // Settings struct holds server settings
@@ -157,8 +169,12 @@ type Settings struct {
 
 	HandleOPTIONS bool
 
+	// Recover from panics in handlers, see Recover for the rendered responses
 	AutoRecover bool // default false
 
+	// Enables development features, e.g. the debug page of recovered panics
+	Development bool // default false
+
 	// ServerName for sending in response headers
 	ServerName string // default ""
 
@@ -212,6 +228,21 @@ type Settings struct {
 
 	// The path of the TLS key
 	TLSKeyPath string // default ""
+
+	// Runtime serving javascript handlers registered with GetJS and friends,
+	// it is closed on Stop when it implements io.Closer
+	JSRuntime js.Runtime // default nil
+
+	// Logger used by the framework and fasthttp
+	Logger LeveledLogger // default NewTextLogger(os.Stderr, LevelInfo)
+
+	// Proxies whose forwarding headers are trusted, as CIDRs or addresses, e.g.
+	// "10.0.0.0/8", see ctx.IP, ctx.Scheme and ctx.Host
+	TrustedProxies []string // default nil (no proxy is trusted)
+
+	// Read PROXY protocol v1 and v2 headers sent by trusted proxies, the address
+	// they carry replaces the peer address of the connection
+	ProxyProtocol bool // default false
 }
 
 // Route struct which holds each route info
@@ -252,6 +283,10 @@ func New(settings ...*Settings) Godzilla {
 		gz.settings.Concurrency = defaultConcurrency
 	}
 
+	if gz.settings.Logger == nil {
+		gz.settings.Logger = NewTextLogger(os.Stderr, LevelInfo)
+	}
+
 	// Initialize router
 	gz.router = &router{
 		settings: gz.settings,
@@ -263,6 +298,8 @@ func New(settings ...*Settings) Godzilla {
 		},
 	}
 
+	gz.router.baseCtx, gz.router.cancelBase = gocontext.WithCancel(gocontext.Background())
+
 	gz.httpServer = gz.newHTTPServer()
 
 	return gz
@@ -275,12 +312,18 @@ func (gz *godzilla) Start(address string) error {
 
 	if gz.settings.Prefork {
 		if !gz.settings.DisableStartupMessage {
-			printStartupMessage(address)
+			gz.printStartupMessage(address)
 		}
 
 		pf := prefork.New(gz.httpServer)
 		pf.Reuseport = true
 		pf.Network = "tcp4"
+		pf.ServeFunc = func(ln net.Listener) error {
+			return gz.httpServer.Serve(gz.wrapListener(ln))
+		}
+		pf.ServeTLSFunc = func(ln net.Listener, certFile, keyFile string) error {
+			return gz.httpServer.ServeTLS(gz.wrapListener(ln), certFile, keyFile)
+		}
 
 		if gz.settings.TLSEnabled {
 			return pf.ListenAndServeTLS(address, gz.settings.TLSCertPath, gz.settings.TLSKeyPath)
@@ -293,9 +336,10 @@ func (gz *godzilla) Start(address string) error {
 		return err
 	}
 	gz.address = address
+	ln = gz.wrapListener(ln)
 
 	if !gz.settings.DisableStartupMessage {
-		printStartupMessage(address)
+		gz.printStartupMessage(address)
 	}
 
 	if gz.settings.TLSEnabled {
@@ -304,17 +348,23 @@ func (gz *godzilla) Start(address string) error {
 	return gz.httpServer.Serve(ln)
 }
 
-// customLogger Customized logger used to filter logging messages
-type customLogger struct{}
+// customLogger forwards fasthttp messages to the framework logger, the messages
+// are dropped when there is none
+type customLogger struct {
+	logger LeveledLogger
+}
 
 func (dl *customLogger) Printf(format string, args ...interface{}) {
+	if dl.logger != nil {
+		dl.logger.Error(fmt.Sprintf(format, args...), "component", "fasthttp")
+	}
 }
 
 // newHTTPServer returns a new instance of fasthttp server
 func (gz *godzilla) newHTTPServer() *fasthttp.Server {
 	return &fasthttp.Server{
 		Handler:                       gz.router.Handler,
-		Logger:                        &customLogger{},
+		Logger:                        &customLogger{logger: gz.settings.Logger},
 		LogAllErrors:                  false,
 		Name:                          gz.settings.ServerName,
 		Concurrency:                   gz.settings.Concurrency,
@@ -352,6 +402,13 @@ func (gz *godzilla) setupRouter() {
 	for _, route := range gz.registeredRoutes {
 		gz.router.handle(route.Method, route.Path, append(gz.middlewares, route.Handlers...))
 	}
+	gz.router.middlewares = gz.middlewares
+
+	trusted, err := parseIPRanges(gz.settings.TrustedProxies)
+	if err != nil {
+		panic("invalid trusted proxy: " + err.Error())
+	}
+	gz.router.trustedProxies = trusted
 
 	// Frees intermediate stores after initializing router
 	gz.registeredRoutes = nil
@@ -360,11 +417,28 @@ func (gz *godzilla) setupRouter() {
 
 // Stop serving
 func (gz *godzilla) Stop() error {
+	// let handlers waiting on ctx.Ctx() return, Shutdown waits for them
+	gz.router.shutdown()
 	err := gz.httpServer.Shutdown()
 
+	// release resources registered with OnStop, e.g. js vm pools
+	for _, hook := range gz.stopHooks {
+		if hookErr := hook(); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	gz.stopHooks = nil
+
+	// the javascript runtime owns the vm pools of its scripts
+	if closer, ok := gz.settings.JSRuntime.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
 	// check if shutdown was ok and server had valid address
 	if err == nil && gz.address != "" {
-		log.Printf("%s stopped listening on %s", name, gz.address)
+		gz.settings.logger().Info(name+" stopped listening", "addr", gz.address)
 		return nil
 	}
 
@@ -498,10 +572,21 @@ func (gz *godzilla) Use(middlewares ...handlerFunc) {
 	gz.middlewares = append(gz.middlewares, middlewares...)
 }
 
-func printStartupMessage(addr string) {
+// Handler returns the request handler of the router, it can be used to serve
+// requests in process, e.g. the fetch calls of javascript handlers
+func (gz *godzilla) Handler() fasthttp.RequestHandler {
+	return gz.router.Handler
+}
+
+// OnStop registers hooks that will be called after the server stops serving
+func (gz *godzilla) OnStop(hooks ...func() error) {
+	gz.stopHooks = append(gz.stopHooks, hooks...)
+}
+
+func (gz *godzilla) printStartupMessage(addr string) {
 	if prefork.IsChild() {
-		log.Printf("Started child proc #%v\n", os.Getpid())
+		gz.settings.logger().Info("Started child proc", "pid", os.Getpid())
 	} else {
-		log.Println(banner, addr, version)
+		gz.settings.logger().Info(fmt.Sprintf(banner, addr), "version", version)
 	}
 }
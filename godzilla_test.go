@@ -35,6 +35,18 @@ func (c *fakeConn) Write(b []byte) (int, error) {
 	return c.w.Write(b)
 }
 
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+}
+
+func (c *fakeConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
 func setupGodzilla(settings ...*Settings) *godzilla {
 	gz := new(godzilla)
 	gz.registeredRoutes = make([]*Route, 0)
@@ -569,3 +581,48 @@ func TestUse(t *testing.T) {
 		t.Fatalf("%s(%s): returned %d expected %d", MethodGet, "/ping", response.StatusCode, StatusUnauthorized)
 	}
 }
+
+// TestOnStop tests that stop hooks are called when the server stops
+func TestOnStop(t *testing.T) {
+	gz := New()
+
+	called := 0
+	gz.OnStop(func() error {
+		called++
+		return nil
+	})
+
+	stopped := make(chan error, 1)
+	go func() {
+		time.Sleep(1000 * time.Millisecond)
+		stopped <- gz.Stop()
+	}()
+
+	gz.Start(":3020")
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop returned error: %s", err)
+	}
+
+	if called != 1 {
+		t.Fatalf("stop hook called %d times expected 1", called)
+	}
+}
+
+// TestHandlerInProcess tests serving requests in process without a connection
+func TestHandlerInProcess(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Get("/ping", pingHandler)
+	startGodzilla(gz)
+
+	var fctx fasthttp.RequestCtx
+	var req fasthttp.Request
+	req.SetRequestURI("/ping")
+	fctx.Init(&req, nil, nil)
+
+	gz.Handler()(&fctx)
+
+	if body := string(fctx.Response.Body()); body != "pong" {
+		t.Fatalf("in process request returned %s expected pong", body)
+	}
+}
@@ -54,7 +54,7 @@ func format(err error) string {
 	for i := 0; i < lineLen; i++ {
 		line := lines[j]
 		if i > 0 {
-			// line = " " + interncolor.dim(line)
+			// line = " " + interncolor.Dim(line)
 			line = " " + line
 		}
 		stack[i] = line
@@ -29,46 +29,46 @@ import (
 var noColor = os.Getenv("NO_COLOR") != ""
 
 // COLORS
-func white(msg string) string {
-	return paint(msg, 226, 232, 240)
+func White(msg string) string {
+	return Paint(msg, 226, 232, 240)
 }
 
-func green(msg string) string {
-	return paint(msg, 43, 255, 99)
+func Green(msg string) string {
+	return Paint(msg, 43, 255, 99)
 }
 
-func blue(msg string) string {
-	return paint(msg, 43, 199, 255)
+func Blue(msg string) string {
+	return Paint(msg, 43, 199, 255)
 }
 
-func yellow(msg string) string {
-	return paint(msg, 255, 237, 43)
+func Yellow(msg string) string {
+	return Paint(msg, 255, 237, 43)
 }
 
-func pink(msg string) string {
-	return paint(msg, 192, 38, 211)
+func Pink(msg string) string {
+	return Paint(msg, 192, 38, 211)
 }
 
-func red(msg string) string {
-	return paint(msg, 255, 43, 43)
+func Red(msg string) string {
+	return Paint(msg, 255, 43, 43)
 }
 
 // COLOR METHODS
-func paint(msg string, r, g, b uint8) string {
+func Paint(msg string, r, g, b uint8) string {
 	if noColor {
 		return msg
 	}
 	return rgbterm.FgString(msg, r, g, b)
 }
 
-func dim(msg string) string {
+func Dim(msg string) string {
 	if noColor {
 		return msg
 	}
 	return "\033[37m" + msg + "\033[0m"
 }
 
-func bold(msg string) string {
+func Bold(msg string) string {
 	if noColor {
 		return msg
 	}
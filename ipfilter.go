@@ -0,0 +1,168 @@
+package godzilla
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+)
+
+// IPFilterConfig holds the ip filter settings, networks are CIDRs or single
+// addresses, ipv4 or ipv6
+type IPFilterConfig struct {
+	Allow []string // default nil (every network is allowed)
+	Deny  []string // default nil, denied networks win over allowed ones
+
+	// Policies of path prefixes, e.g. "/admin", requests must pass the policy
+	// of the longest matching prefix in addition to the global one. Prefixes
+	// ignore case when Settings.CaseInSensitive is set.
+	Groups map[string]*IPPolicy // default nil
+
+	// Answers denied requests
+	Forbidden func(ctx Context) // default 403 Forbidden
+}
+
+// IPPolicy lists the networks allowed and denied to make requests, denied
+// networks win over allowed ones
+type IPPolicy struct {
+	Allow []string // default nil (every network is allowed)
+	Deny  []string // default nil
+}
+
+// IPFilter filters requests by client ip, see ctx.IP for how the ip is
+// resolved behind trusted proxies. Its policies can be reloaded at runtime.
+type IPFilter struct {
+	rules     atomic.Value // *ipRules
+	forbidden func(ctx Context)
+}
+
+type ipRules struct {
+	global ipPolicy
+	groups []ipGroup // longest prefix first
+}
+
+type ipPolicy struct {
+	allow ipRanges
+	deny  ipRanges
+}
+
+type ipGroup struct {
+	prefix string
+	folded string // prefix matched against lowercased paths, see Settings.CaseInSensitive
+	policy ipPolicy
+}
+
+// NewIPFilter returns a filter with the policies of config, an error is
+// returned when a network cannot be parsed
+func NewIPFilter(config *IPFilterConfig) (*IPFilter, error) {
+	f := &IPFilter{forbidden: config.Forbidden}
+	if f.forbidden == nil {
+		f.forbidden = func(ctx Context) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusForbidden),
+				fasthttp.StatusForbidden)
+		}
+	}
+
+	if err := f.Reload(config); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload replaces the global and group policies, Forbidden is not replaced.
+// The current policies are kept when config is invalid.
+func (f *IPFilter) Reload(config *IPFilterConfig) error {
+	rules := &ipRules{}
+
+	var err error
+	if rules.global, err = (&IPPolicy{Allow: config.Allow, Deny: config.Deny}).parse(); err != nil {
+		return err
+	}
+
+	for prefix, policy := range config.Groups {
+		group := ipGroup{prefix: strings.TrimSuffix(prefix, "/")}
+		group.folded = strings.ToLower(group.prefix)
+		if group.policy, err = policy.parse(); err != nil {
+			return err
+		}
+		rules.groups = append(rules.groups, group)
+	}
+	sort.Slice(rules.groups, func(i, j int) bool {
+		return len(rules.groups[i].prefix) > len(rules.groups[j].prefix)
+	})
+
+	f.rules.Store(rules)
+	return nil
+}
+
+// Allowed reports whether ip may request path, group prefixes are matched
+// case-sensitively
+func (f *IPFilter) Allowed(ip net.IP, path string) bool {
+	return f.allowed(ip, path, false)
+}
+
+// allowed matches lowercased group prefixes when path was folded to lowercase
+func (f *IPFilter) allowed(ip net.IP, path string, folded bool) bool {
+	rules := f.rules.Load().(*ipRules)
+	if !rules.global.allows(ip) {
+		return false
+	}
+
+	for _, group := range rules.groups {
+		prefix := group.prefix
+		if folded {
+			prefix = group.folded
+		}
+		if hasPathPrefix(path, prefix) {
+			return group.policy.allows(ip)
+		}
+	}
+	return true
+}
+
+// Handler returns the middleware answering requests of denied clients with
+// Forbidden, groups are matched against the path the router routes on
+func (f *IPFilter) Handler() handlerFunc {
+	return func(ctx Context) {
+		path, folded := routingPath(ctx)
+		if !f.allowed(net.ParseIP(ctx.IP()), path, folded) {
+			f.forbidden(ctx)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func (p *IPPolicy) parse() (ipPolicy, error) {
+	if p == nil {
+		return ipPolicy{}, nil
+	}
+
+	allow, err := parseIPRanges(p.Allow)
+	if err != nil {
+		return ipPolicy{}, err
+	}
+	deny, err := parseIPRanges(p.Deny)
+	if err != nil {
+		return ipPolicy{}, err
+	}
+	return ipPolicy{allow: allow, deny: deny}, nil
+}
+
+func (p ipPolicy) allows(ip net.IP) bool {
+	if p.deny.contains(ip) {
+		return false
+	}
+	return len(p.allow) == 0 || p.allow.contains(ip)
+}
+
+// hasPathPrefix reports whether path is prefix or below it, "/admin" matches
+// "/admin/users" but not "/administrator"
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/' || prefix == ""
+}
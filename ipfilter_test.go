@@ -0,0 +1,89 @@
+package godzilla
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestIPFilter tests global and group policies and reloading them
+func TestIPFilter(t *testing.T) {
+	filter, err := NewIPFilter(&IPFilterConfig{
+		Deny: []string{"203.0.113.0/24", "2001:db8:bad::/48"},
+		Groups: map[string]*IPPolicy{
+			"/admin":       {Allow: []string{"192.168.10.0/24", "2001:db8:1::/48"}},
+			"/admin/guest": {Deny: []string{"192.168.10.66"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := setupGodzilla(&Settings{TrustedProxies: []string{"127.0.0.1"}})
+	gz.Use(filter.Handler())
+	gz.Get("/", pingHandler)
+	gz.Get("/admin", pingHandler)
+	gz.Get("/admin/guest", pingHandler)
+	gz.Get("/administrator", pingHandler)
+	startGodzilla(gz)
+
+	type testCase struct {
+		ip         string
+		path       string
+		statusCode int
+	}
+
+	check := func(testCases []testCase) {
+		for _, tc := range testCases {
+			req, _ := http.NewRequest(MethodGet, tc.path, nil)
+			req.Header.Set(HeaderXForwardedFor, tc.ip)
+			response, err := makeRequest(req, gz)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if response.StatusCode != tc.statusCode {
+				t.Fatalf("%s %s: returned %d expected %d", tc.ip, tc.path, response.StatusCode, tc.statusCode)
+			}
+		}
+	}
+
+	testCases := []testCase{
+		{ip: "198.51.100.1", path: "/", statusCode: StatusOK},
+		{ip: "203.0.113.5", path: "/", statusCode: StatusForbidden},
+		{ip: "2001:db8:bad::1", path: "/", statusCode: StatusForbidden},
+		{ip: "198.51.100.1", path: "/admin", statusCode: StatusForbidden},
+		{ip: "198.51.100.1", path: "/administrator", statusCode: StatusOK},
+		{ip: "192.168.10.5", path: "/admin", statusCode: StatusOK},
+		{ip: "2001:db8:1::5", path: "/admin", statusCode: StatusOK},
+		{ip: "192.168.10.66", path: "/admin/guest", statusCode: StatusForbidden},
+		{ip: "198.51.100.1", path: "/admin/guest", statusCode: StatusOK},
+	}
+	check(testCases)
+
+	// invalid policies keep the current ones
+	if err := filter.Reload(&IPFilterConfig{Allow: []string{"not an ip"}}); err == nil {
+		t.Fatalf("reloaded invalid networks")
+	}
+	check(testCases)
+
+	if err := filter.Reload(&IPFilterConfig{Allow: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatal(err)
+	}
+	check([]testCase{
+		{ip: "192.168.10.5", path: "/admin", statusCode: StatusForbidden},
+		{ip: "10.1.2.3", path: "/admin", statusCode: StatusOK},
+		{ip: "203.0.113.5", path: "/", statusCode: StatusForbidden},
+	})
+
+	// groups match the path the router routes on, whatever its case
+	folded, _ := NewIPFilter(&IPFilterConfig{Groups: map[string]*IPPolicy{"/Admin": {Allow: []string{"192.168.10.0/24"}}}})
+	gz = setupGodzilla(&Settings{TrustedProxies: []string{"127.0.0.1"}, CaseInSensitive: true})
+	gz.Use(folded.Handler())
+	gz.Get("/admin", pingHandler)
+	startGodzilla(gz)
+	check([]testCase{
+		{ip: "198.51.100.1", path: "/admin", statusCode: StatusForbidden},
+		{ip: "198.51.100.1", path: "/ADMIN", statusCode: StatusForbidden},
+		{ip: "192.168.10.5", path: "/aDmIn", statusCode: StatusOK},
+	})
+}
@@ -0,0 +1,128 @@
+package godzilla
+
+import (
+	"github.com/godzillaframework/godzilla/container/js"
+	"github.com/valyala/fasthttp"
+)
+
+// GetJS registers a javascript handler script for GET requests
+func (gz *godzilla) GetJS(path, script string) *Route {
+	return gz.Get(path, gz.JS(script))
+}
+
+// PostJS registers a javascript handler script for POST requests
+func (gz *godzilla) PostJS(path, script string) *Route {
+	return gz.Post(path, gz.JS(script))
+}
+
+// PutJS registers a javascript handler script for PUT requests
+func (gz *godzilla) PutJS(path, script string) *Route {
+	return gz.Put(path, gz.JS(script))
+}
+
+// PatchJS registers a javascript handler script for PATCH requests
+func (gz *godzilla) PatchJS(path, script string) *Route {
+	return gz.Patch(path, gz.JS(script))
+}
+
+// DeleteJS registers a javascript handler script for DELETE requests
+func (gz *godzilla) DeleteJS(path, script string) *Route {
+	return gz.Delete(path, gz.JS(script))
+}
+
+// UseJS registers a javascript middleware script for all routes, the request
+// continues to the next handler when the script returns no response
+func (gz *godzilla) UseJS(script string) {
+	gz.Use(gz.jsHandler(script, true))
+}
+
+// JS returns a handler that answers requests with the function exported by
+// script, it can be combined with other handlers on any route
+func (gz *godzilla) JS(script string) handlerFunc {
+	return gz.jsHandler(script, false)
+}
+
+func (gz *godzilla) jsHandler(script string, middleware bool) handlerFunc {
+	if gz.settings.JSRuntime == nil {
+		panic("no javascript runtime provided for script '" + script + "'")
+	}
+
+	runtime := gz.settings.JSRuntime
+	return func(ctx Context) {
+		goctx := ctx.Ctx()
+		if id := ctx.RequestID(); id != "" {
+			goctx = js.WithRequestID(goctx, id)
+		}
+
+		res, err := runtime.Handle(goctx, script, newJSRequest(ctx))
+		if err != nil {
+			requestLogger(ctx).Error("javascript handler failed", "script", script, "error", err)
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError),
+				fasthttp.StatusInternalServerError)
+			return
+		}
+
+		if res == nil {
+			if middleware {
+				ctx.Next()
+			}
+			return
+		}
+
+		if err := sendJSResponse(ctx, res); err != nil {
+			requestLogger(ctx).Error("javascript handler returned invalid body", "script", script, "error", err)
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError),
+				fasthttp.StatusInternalServerError)
+		}
+	}
+}
+
+// newJSRequest copies the request into the object passed to scripts
+func newJSRequest(ctx Context) *js.Request {
+	fctx := ctx.Context()
+
+	req := &js.Request{
+		Method:  GetString(fctx.Method()),
+		Path:    GetString(fctx.Path()),
+		Params:  make(map[string]string),
+		Query:   make(map[string]string),
+		Headers: make(map[string]string),
+		Body:    ctx.Body(),
+	}
+
+	if c, ok := ctx.(*context); ok {
+		for key, value := range c.paramValues {
+			req.Params[key] = value
+		}
+	}
+
+	fctx.QueryArgs().VisitAll(func(key, value []byte) {
+		req.Query[string(key)] = string(value)
+	})
+
+	fctx.Request.Header.VisitAll(func(key, value []byte) {
+		req.Headers[string(key)] = string(value)
+	})
+	return req
+}
+
+// sendJSResponse writes the response returned by a script
+func sendJSResponse(ctx Context, res *js.Response) error {
+	if res.Status > 0 {
+		ctx.Status(res.Status)
+	}
+
+	for key, value := range res.Headers {
+		ctx.Set(key, value)
+	}
+
+	switch body := res.Body.(type) {
+	case nil:
+		return nil
+	case string:
+		ctx.SendString(body)
+		return nil
+	default:
+		return ctx.SendJSON(body)
+	}
+}
@@ -0,0 +1,98 @@
+package godzilla
+
+import (
+	gocontext "context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/godzillaframework/godzilla/container/js"
+)
+
+// fakeRuntime answers javascript handler calls without a javascript engine
+type fakeRuntime struct{}
+
+func (fakeRuntime) Handle(ctx gocontext.Context, script string, req *js.Request) (*js.Response, error) {
+	switch script {
+	case "hello.js":
+		return &js.Response{
+			Status:  StatusCreated,
+			Headers: map[string]string{"X-Script": script},
+			Body:    "hello " + req.Params["name"] + " " + req.Query["greeting"],
+		}, nil
+	case "json.js":
+		return &js.Response{Body: map[string]string{"method": req.Method}}, nil
+	case "guard.js":
+		if req.Headers["X-Token"] != "secret" {
+			return &js.Response{Status: StatusUnauthorized}, nil
+		}
+	}
+	return nil, nil
+}
+
+// TestJSHandlers tests javascript route handlers and middlewares
+func TestJSHandlers(t *testing.T) {
+	gz := setupGodzilla(&Settings{JSRuntime: fakeRuntime{}})
+
+	gz.GetJS("/hello/:name", "hello.js")
+	gz.PostJS("/json", "json.js")
+	gz.Get("/guarded", gz.JS("guard.js"), pingHandler)
+	gz.UseJS("guard.js")
+
+	startGodzilla(gz)
+
+	testCases := []struct {
+		method     string
+		path       string
+		token      string
+		statusCode int
+		body       string
+		headers    map[string]string
+	}{
+		{method: MethodGet, path: "/hello/gz?greeting=hi", token: "secret", statusCode: StatusCreated, body: "hello gz hi", headers: map[string]string{"X-Script": "hello.js"}},
+		{method: MethodGet, path: "/hello/gz", statusCode: StatusUnauthorized},
+		{method: MethodPost, path: "/json", token: "secret", statusCode: StatusOK, body: `{"method":"POST"}`, headers: map[string]string{"Content-Type": MimeApplicationJSON}},
+		{method: MethodGet, path: "/guarded", token: "secret", statusCode: StatusOK},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("X-Token", tc.token)
+		}
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.method, tc.path, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s(%s): returned %d expected %d", tc.method, tc.path, response.StatusCode, tc.statusCode)
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.method, tc.path, err.Error())
+		}
+
+		if string(body) != tc.body {
+			t.Fatalf("%s(%s): returned %s expected %s", tc.method, tc.path, body, tc.body)
+		}
+
+		for expectedKey, expectedValue := range tc.headers {
+			if actualValue := response.Header.Get(expectedKey); actualValue != expectedValue {
+				t.Errorf("%s(%s): header '%s' actual '%s', expected '%s'",
+					tc.method, tc.path, expectedKey, actualValue, expectedValue)
+			}
+		}
+	}
+}
+
+// TestJSWithoutRuntime tests that registering scripts requires a runtime
+func TestJSWithoutRuntime(t *testing.T) {
+	gz := setupGodzilla()
+
+	if recv := catchPanic(func() { gz.GetJS("/hello", "hello.js") }); recv == nil {
+		t.Fatalf("no panic for javascript handler without runtime")
+	}
+}
@@ -0,0 +1,442 @@
+package godzilla
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+// JWT signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	// ErrTokenMalformed is returned for tokens that cannot be parsed
+	ErrTokenMalformed = errors.New("jwt: malformed token")
+
+	// ErrTokenSignature is returned when no key verifies the token signature
+	ErrTokenSignature = errors.New("jwt: invalid signature")
+
+	// ErrTokenAlgorithm is returned for tokens signed with an algorithm that is not allowed
+	ErrTokenAlgorithm = errors.New("jwt: algorithm not allowed")
+
+	// ErrTokenExpired is returned for tokens past their exp claim
+	ErrTokenExpired = errors.New("jwt: token is expired")
+
+	// ErrTokenNoExpiration is returned for tokens without exp claim when
+	// JWTConfig.RequireExp is set
+	ErrTokenNoExpiration = errors.New("jwt: token has no exp claim")
+
+	// ErrTokenNotValidYet is returned for tokens before their nbf claim
+	ErrTokenNotValidYet = errors.New("jwt: token is not valid yet")
+
+	// ErrTokenIssuer is returned when the iss claim does not match
+	ErrTokenIssuer = errors.New("jwt: invalid issuer")
+
+	// ErrTokenAudience is returned when the aud claim does not contain the audience
+	ErrTokenAudience = errors.New("jwt: invalid audience")
+)
+
+// JWTConfig holds the jwt middleware settings
+type JWTConfig struct {
+	// Key of HS256 tokens
+	Secret []byte // default nil
+
+	// Path of a local JWKS file with the public keys of RS256, ES256 and EdDSA
+	// tokens, and the secrets of HS256 tokens
+	JWKSFile string // default ""
+
+	// Accepted signing algorithms
+	Algorithms []string // default every algorithm with a key
+
+	// Expected iss claim
+	Issuer string // default "" (not checked)
+
+	// Audience that must be in the aud claim
+	Audience string // default "" (not checked)
+
+	// Clock skew tolerated when checking exp and nbf
+	Leeway time.Duration // default 0
+
+	// Rejects tokens without exp claim, which would otherwise never expire
+	RequireExp bool // default false
+
+	// Where the token is read from, see KeyAuthConfig.Lookup
+	Lookup string // default "header:Authorization" with the Bearer scheme
+
+	// Answers requests without a valid token
+	Unauthorized func(ctx Context, err error) // default 401 with WWW-Authenticate
+}
+
+// Claims holds the registered claims of a verified token, other claims can
+// be read with Decode
+type Claims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  Audience    `json:"aud,omitempty"`
+	ExpiresAt NumericDate `json:"exp,omitempty"`
+	NotBefore NumericDate `json:"nbf,omitempty"`
+	IssuedAt  NumericDate `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+
+	raw []byte
+}
+
+// Decode unmarshals the claims into out, e.g. a struct with custom claims
+func (c *Claims) Decode(out interface{}) error {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(c.raw, out)
+}
+
+// Audience is the aud claim, a single string or an array of strings
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// NumericDate is a time in seconds since the epoch, as used by exp, nbf and iat
+type NumericDate int64
+
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("jwt: invalid numeric date %s", data)
+	}
+	*d = NumericDate(seconds)
+	return nil
+}
+
+// Time returns the date as a time.Time
+func (d NumericDate) Time() time.Time {
+	return time.Unix(int64(d), 0)
+}
+
+// JWTClaims returns the claims of the token verified by JWT
+func JWTClaims(ctx Context) *Claims {
+	claims, _ := ctx.GetLocal(localJWTClaims).(*Claims)
+	return claims
+}
+
+// JWT returns a middleware verifying json web tokens, the claims are
+// available with JWTClaims
+func JWT(config *JWTConfig) handlerFunc {
+	verifier, err := newJWTVerifier(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	extract := keyExtractor(config.Lookup, "")
+
+	unauthorized := config.Unauthorized
+	if unauthorized == nil {
+		unauthorized = func(ctx Context, err error) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized),
+				fasthttp.StatusUnauthorized)
+			ctx.Set(HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		}
+	}
+
+	return func(ctx Context) {
+		token := extract(ctx)
+		if token == "" {
+			unauthorized(ctx, ErrMissingKey)
+			return
+		}
+
+		claims, err := verifier.verify(token, time.Now())
+		if err != nil {
+			unauthorized(ctx, err)
+			return
+		}
+
+		ctx.SetLocal(localJWTClaims, claims)
+		ctx.Next()
+	}
+}
+
+// jwk is a verification key, kid is empty for keys without id
+type jwk struct {
+	kid string
+	alg string
+	key interface{} // []byte, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+}
+
+type jwtVerifier struct {
+	keys       []jwk
+	algorithms map[string]bool
+	config     *JWTConfig
+}
+
+func newJWTVerifier(config *JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{config: config, algorithms: make(map[string]bool)}
+
+	if len(config.Secret) > 0 {
+		v.keys = append(v.keys, jwk{alg: AlgHS256, key: config.Secret})
+	}
+
+	if config.JWKSFile != "" {
+		data, err := ioutil.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %s: %w", config.JWKSFile, err)
+		}
+		v.keys = append(v.keys, keys...)
+	}
+
+	if len(v.keys) == 0 {
+		return nil, errors.New("jwt: no secret or jwks file provided")
+	}
+
+	if len(config.Algorithms) > 0 {
+		for _, alg := range config.Algorithms {
+			v.algorithms[alg] = true
+		}
+	} else {
+		for _, key := range v.keys {
+			v.algorithms[key.alg] = true
+		}
+	}
+	return v, nil
+}
+
+func (v *jwtVerifier) verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	// "none" and unknown algorithms are never in the allowed set
+	if !v.algorithms[header.Alg] {
+		return nil, ErrTokenAlgorithm
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	signed := []byte(token[:len(parts[0])+1+len(parts[1])])
+	if !v.verifySignature(header.Alg, header.Kid, signed, signature) {
+		return nil, ErrTokenSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	claims := &Claims{raw: payload}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	return claims, v.validate(claims, now)
+}
+
+// verifySignature tries the keys matching kid, or every key when the token
+// has no kid, that can verify alg
+func (v *jwtVerifier) verifySignature(alg, kid string, signed, signature []byte) bool {
+	for _, key := range v.keys {
+		if kid != "" && key.kid != "" && key.kid != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		if verifyJWS(alg, key.key, signed, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyJWS(alg string, key interface{}, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	switch k := key.(type) {
+	case []byte:
+		if alg != AlgHS256 {
+			return false
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case *rsa.PublicKey:
+		return alg == AlgRS256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		if alg != AlgES256 || k.Curve != elliptic.P256() || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA && ed25519.Verify(k, signed, signature)
+	}
+	return false
+}
+
+func (v *jwtVerifier) validate(claims *Claims, now time.Time) error {
+	leeway := int64(v.config.Leeway / time.Second)
+
+	if claims.ExpiresAt == 0 && v.config.RequireExp {
+		return ErrTokenNoExpiration
+	}
+
+	if claims.ExpiresAt != 0 && now.Unix() >= int64(claims.ExpiresAt)+leeway {
+		return ErrTokenExpired
+	}
+
+	if claims.NotBefore != 0 && now.Unix() < int64(claims.NotBefore)-leeway {
+		return ErrTokenNotValidYet
+	}
+
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return ErrTokenIssuer
+	}
+
+	if v.config.Audience != "" {
+		for _, aud := range claims.Audience {
+			if aud == v.config.Audience {
+				return nil
+			}
+		}
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+// parseJWKS parses the keys of a JSON Web Key Set, keys used for encryption
+// only and keys of unsupported types or algorithms are skipped
+func parseJWKS(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+
+		key := jwk{kid: k.Kid, alg: k.Alg}
+
+		switch {
+		case k.Kty == "oct":
+			secret, err := decodeSegment(k.K)
+			if err != nil {
+				return nil, err
+			}
+			key.key = secret
+			key.alg = AlgHS256
+		case k.Kty == "RSA":
+			n, e, err := decodeInts(k.N, k.E)
+			if err != nil {
+				return nil, err
+			}
+			key.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+			key.alg = AlgRS256
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, y, err := decodeInts(k.X, k.Y)
+			if err != nil {
+				return nil, err
+			}
+			if !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("key %q is not on curve P-256", k.Kid)
+			}
+			key.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+			key.alg = AlgES256
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			x, err := decodeSegment(k.X)
+			if err != nil {
+				return nil, err
+			}
+			if len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("key %q is not an ed25519 key", k.Kid)
+			}
+			key.key = ed25519.PublicKey(x)
+			key.alg = AlgEdDSA
+		default:
+			// unsupported key types are ignored, they may be used by other services
+			continue
+		}
+
+		if k.Alg != "" && k.Alg != key.alg {
+			// e.g. PS256 or RS512 keys of a supported type, used by other services
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func decodeInts(a, b string) (*big.Int, *big.Int, error) {
+	rawA, err := decodeSegment(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	rawB, err := decodeSegment(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(rawA), new(big.Int).SetBytes(rawB), nil
+}
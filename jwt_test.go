@@ -0,0 +1,162 @@
+package godzilla
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// signJWT signs claims with key for tests
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	default:
+		t.Fatalf("unsupported key %T", key)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// TestJWT tests token verification with keys from a jwks file
+func TestJWT(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("hmac-secret")
+
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": %q, "e": %q},
+		{"kty": "RSA", "kid": "pss", "alg": "PS256", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": %q},
+		{"kty": "oct", "kid": "hs", "k": %q}
+	]}`,
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.Bytes()), b64(ecKey.Y.Bytes()),
+		b64(edPublic), b64(secret))
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(jwks), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var reported error
+
+	gz := setupGodzilla()
+	gz.Use(JWT(&JWTConfig{
+		JWKSFile:   path,
+		Issuer:     "https://auth.example.com",
+		Audience:   "api",
+		RequireExp: true,
+		Unauthorized: func(ctx Context, err error) {
+			reported = err
+			ctx.Status(StatusUnauthorized)
+		},
+	}))
+	gz.Get("/me", func(ctx Context) {
+		var custom struct {
+			Role string `json:"role"`
+		}
+		claims := JWTClaims(ctx)
+		claims.Decode(&custom)
+		ctx.SendString(claims.Subject + " " + custom.Role)
+	})
+	startGodzilla(gz)
+
+	now := time.Now().Unix()
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://auth.example.com", "aud": []string{"web", "api"}, "sub": "gopher",
+			"exp": now + 60, "nbf": now - 60, "role": "admin",
+		}
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := valid()
+		claims[key] = value
+		return claims
+	}
+	without := func(key string) map[string]interface{} {
+		claims := valid()
+		delete(claims, key)
+		return claims
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "rs256", token: signJWT(t, AlgRS256, "rsa", rsaKey, valid())},
+		{name: "es256", token: signJWT(t, AlgES256, "ec", ecKey, valid())},
+		{name: "eddsa", token: signJWT(t, AlgEdDSA, "ed", edKey, valid())},
+		{name: "hs256 without kid", token: signJWT(t, AlgHS256, "", secret, valid())},
+		{name: "string audience", token: signJWT(t, AlgHS256, "hs", secret, with("aud", "api"))},
+		{name: "expired", token: signJWT(t, AlgRS256, "rsa", rsaKey, with("exp", now-1)), err: ErrTokenExpired},
+		{name: "no expiration", token: signJWT(t, AlgRS256, "rsa", rsaKey, without("exp")), err: ErrTokenNoExpiration},
+		{name: "not valid yet", token: signJWT(t, AlgRS256, "rsa", rsaKey, with("nbf", now+60)), err: ErrTokenNotValidYet},
+		{name: "issuer", token: signJWT(t, AlgRS256, "rsa", rsaKey, with("iss", "https://evil.com")), err: ErrTokenIssuer},
+		{name: "audience", token: signJWT(t, AlgRS256, "rsa", rsaKey, with("aud", "web")), err: ErrTokenAudience},
+		{name: "wrong key", token: signJWT(t, AlgHS256, "hs", []byte("guess"), valid()), err: ErrTokenSignature},
+		{name: "algorithm confusion", token: signJWT(t, AlgHS256, "rsa", []byte(b64(rsaKey.N.Bytes())), valid()), err: ErrTokenSignature},
+		{name: "none", token: "eyJhbGciOiJub25lIn0.e30.", err: ErrTokenAlgorithm},
+		{name: "malformed", token: "token", err: ErrTokenMalformed},
+	}
+
+	for _, tc := range testCases {
+		reported = nil
+
+		req, _ := http.NewRequest(MethodGet, "/me", nil)
+		req.Header.Set(HeaderAuthorization, "Bearer "+tc.token)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err.Error())
+		}
+
+		if reported != tc.err {
+			t.Fatalf("%s: reported %v expected %v", tc.name, reported, tc.err)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		if tc.err == nil && string(body) != "gopher admin" {
+			t.Fatalf("%s: returned %s expected gopher admin", tc.name, body)
+		}
+	}
+}
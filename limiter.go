@@ -0,0 +1,383 @@
+package godzilla
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Rate limit headers
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// LimitAlgorithm selects how requests are counted
+type LimitAlgorithm int
+
+// Rate limit algorithms
+const (
+	// TokenBucket refills Limit tokens per Period up to Burst, smoothing bursts
+	TokenBucket LimitAlgorithm = iota
+
+	// FixedWindow allows Limit requests per Period starting at the first request
+	FixedWindow
+
+	// SlidingLog allows Limit requests in any Period, it keeps one timestamp
+	// per request
+	SlidingLog
+)
+
+// Rate is the number of requests allowed per period
+type Rate struct {
+	Algorithm LimitAlgorithm // default TokenBucket
+	Limit     int            // default 100
+	Period    time.Duration  // default time.Minute
+	Burst     int            // token bucket size, default Limit
+}
+
+// LimitResult is the state of a key after a request was counted
+type LimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the full limit is available again
+	RetryAfter time.Duration // until the next request is allowed, set when denied
+}
+
+// LimiterStore keeps the counters of rate limited keys, implementations must
+// be safe for concurrent use
+type LimiterStore interface {
+	// Take counts a request for key and reports whether it is allowed
+	Take(key string, rate Rate, now time.Time) (LimitResult, error)
+}
+
+// LimiterConfig holds the limiter middleware settings
+type LimiterConfig struct {
+	// Rate of requests allowed per key
+	Rate Rate // default 100 requests per minute, token bucket
+
+	// Rates of specific routes keyed by route path, e.g. "/login"
+	Routes map[string]Rate // default nil
+
+	// Returns the key requests are counted under
+	Key func(ctx Context) string // default KeyByIP()
+
+	// Counters storage
+	Store LimiterStore // default in-memory store removing expired keys while counting
+
+	// Requests for which Skip returns true are not counted
+	Skip func(ctx Context) bool // default nil
+
+	// Answers denied requests
+	LimitReached func(ctx Context) // default 429 Too Many Requests
+
+	// Do not send RateLimit-* headers
+	DisableHeaders bool // default false
+}
+
+// KeyByIP counts requests per client ip, see Settings.TrustedProxies
+func KeyByIP() func(ctx Context) string {
+	return func(ctx Context) string {
+		return ctx.IP()
+	}
+}
+
+// KeyByHeader counts requests per value of a request header, e.g. an api key,
+// requests without the header are counted per client ip
+func KeyByHeader(name string) func(ctx Context) string {
+	return func(ctx Context) string {
+		if value := ctx.Get(name); value != "" {
+			return name + ":" + value
+		}
+		return ctx.IP()
+	}
+}
+
+// KeyByRoute counts requests per route, whoever makes them
+func KeyByRoute() func(ctx Context) string {
+	return func(ctx Context) string {
+		return ctx.Route()
+	}
+}
+
+// Limiter returns a middleware limiting the rate of requests per key, denied
+// requests are answered with 429 and Retry-After
+func Limiter(config ...*LimiterConfig) handlerFunc {
+	cfg := &LimiterConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	rate := cfg.Rate.withDefaults()
+	routes := make(map[string]Rate, len(cfg.Routes))
+	for route, r := range cfg.Routes {
+		routes[route] = r.withDefaults()
+	}
+
+	key := cfg.Key
+	if key == nil {
+		key = KeyByIP()
+	}
+
+	store := cfg.Store
+	if store == nil {
+		// no goroutine nobody could stop, expired keys are removed by Take
+		store = newMemoryStore(true)
+	}
+
+	limitReached := cfg.LimitReached
+	if limitReached == nil {
+		limitReached = func(ctx Context) {
+			ctx.Context().Error(fasthttp.StatusMessage(fasthttp.StatusTooManyRequests),
+				fasthttp.StatusTooManyRequests)
+		}
+	}
+
+	return func(ctx Context) {
+		if cfg.Skip != nil && cfg.Skip(ctx) {
+			ctx.Next()
+			return
+		}
+
+		// overridden routes get their own counters
+		r, k := rate, key(ctx)
+		if route, ok := routes[ctx.Route()]; ok {
+			r, k = route, ctx.Route()+"\x00"+k
+		}
+
+		result, err := store.Take(k, r, time.Now())
+		if err != nil {
+			// a broken store must not take the service down
+			requestLogger(ctx).Error("rate limiter store failed", "error", err)
+			ctx.Next()
+			return
+		}
+
+		if !result.Allowed {
+			limitReached(ctx)
+
+			// set after the handler, fasthttp errors reset the response headers
+			ctx.Set(HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			if !cfg.DisableHeaders {
+				setRateLimitHeaders(ctx, result)
+			}
+			return
+		}
+
+		if !cfg.DisableHeaders {
+			setRateLimitHeaders(ctx, result)
+		}
+		ctx.Next()
+	}
+}
+
+func setRateLimitHeaders(ctx Context, result LimitResult) {
+	ctx.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	ctx.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	ctx.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func (r Rate) withDefaults() Rate {
+	if r.Limit <= 0 {
+		r.Limit = 100
+	}
+	if r.Period <= 0 {
+		r.Period = time.Minute
+	}
+	if r.Burst <= 0 {
+		r.Burst = r.Limit
+	}
+	return r
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// memoryShards is the number of independently locked parts of a MemoryStore
+const memoryShards = 64
+
+// MemoryStore is an in-memory LimiterStore, keys are spread over shards to
+// reduce lock contention and expire once their counters are back to full
+type MemoryStore struct {
+	sweep  int64 // unix nano time of the next removal by Take, first for 64-bit alignment
+	lazy   bool  // expired keys are removed by Take instead of a goroutine
+	shards [memoryShards]memoryShard
+	done   chan struct{}
+	once   sync.Once
+}
+
+type memoryShard struct {
+	mutex   sync.Mutex
+	entries map[string]*limitEntry
+}
+
+type limitEntry struct {
+	tokens  float64     // token bucket
+	last    time.Time   // token bucket refill time, fixed window start
+	count   int         // fixed window
+	log     []time.Time // sliding log
+	expires time.Time
+}
+
+// NewMemoryStore returns an in-memory store removing expired keys every minute
+func NewMemoryStore() *MemoryStore {
+	s := newMemoryStore(false)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				s.expire(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func newMemoryStore(lazy bool) *MemoryStore {
+	s := &MemoryStore{done: make(chan struct{}), lazy: lazy, sweep: time.Now().Add(time.Minute).UnixNano()}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*limitEntry)
+	}
+	return s
+}
+
+// Take counts a request for key
+func (s *MemoryStore) Take(key string, rate Rate, now time.Time) (LimitResult, error) {
+	if s.lazy {
+		// a single caller wins the swap and removes expired keys at most once a minute
+		next := atomic.LoadInt64(&s.sweep)
+		if now.UnixNano() >= next && atomic.CompareAndSwapInt64(&s.sweep, next, now.Add(time.Minute).UnixNano()) {
+			s.expire(now)
+		}
+	}
+
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	entry := shard.entries[key]
+	if entry == nil || !now.Before(entry.expires) {
+		entry = &limitEntry{tokens: float64(rate.Burst), last: now}
+
+		// key may alias a request buffer, see GetString
+		shard.entries[string([]byte(key))] = entry
+	}
+
+	switch rate.Algorithm {
+	case FixedWindow:
+		return entry.fixedWindow(rate, now), nil
+	case SlidingLog:
+		return entry.slidingLog(rate, now), nil
+	default:
+		return entry.tokenBucket(rate, now), nil
+	}
+}
+
+// Close stops removing expired keys
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%memoryShards]
+}
+
+func (s *MemoryStore) expire(now time.Time) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		for key, entry := range shard.entries {
+			if !now.Before(entry.expires) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
+
+func (e *limitEntry) tokenBucket(rate Rate, now time.Time) LimitResult {
+	perSecond := float64(rate.Limit) / rate.Period.Seconds()
+
+	e.tokens = math.Min(float64(rate.Burst), e.tokens+now.Sub(e.last).Seconds()*perSecond)
+	e.last = now
+
+	result := LimitResult{Limit: rate.Burst}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - e.tokens) / perSecond)
+	}
+
+	result.Remaining = int(e.tokens)
+	result.Reset = seconds((float64(rate.Burst) - e.tokens) / perSecond)
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+func (e *limitEntry) fixedWindow(rate Rate, now time.Time) LimitResult {
+	if now.Sub(e.last) >= rate.Period {
+		e.last, e.count = now, 0
+	}
+
+	e.count++
+	e.expires = e.last.Add(rate.Period)
+
+	result := LimitResult{
+		Allowed:   e.count <= rate.Limit,
+		Limit:     rate.Limit,
+		Remaining: rate.Limit - e.count,
+		Reset:     e.expires.Sub(now),
+	}
+
+	if !result.Allowed {
+		e.count = rate.Limit
+		result.Remaining = 0
+		result.RetryAfter = result.Reset
+	}
+	return result
+}
+
+func (e *limitEntry) slidingLog(rate Rate, now time.Time) LimitResult {
+	// drop requests that left the window
+	start := now.Add(-rate.Period)
+	i := 0
+	for i < len(e.log) && !e.log[i].After(start) {
+		i++
+	}
+	e.log = e.log[i:]
+
+	result := LimitResult{Limit: rate.Limit}
+	if len(e.log) < rate.Limit {
+		e.log = append(e.log, now)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = e.log[0].Add(rate.Period).Sub(now)
+	}
+
+	result.Remaining = rate.Limit - len(e.log)
+	result.Reset = e.log[len(e.log)-1].Add(rate.Period).Sub(now)
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
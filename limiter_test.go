@@ -0,0 +1,117 @@
+package godzilla
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestLimiterAlgorithms tests the algorithms of the memory store
+func TestLimiterAlgorithms(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	now := time.Now()
+
+	testCases := []struct {
+		name string
+		rate Rate
+	}{
+		{name: "token bucket", rate: Rate{Algorithm: TokenBucket, Limit: 2, Period: time.Second}},
+		{name: "fixed window", rate: Rate{Algorithm: FixedWindow, Limit: 2, Period: time.Second}},
+		{name: "sliding log", rate: Rate{Algorithm: SlidingLog, Limit: 2, Period: time.Second}},
+	}
+
+	for _, tc := range testCases {
+		// two requests are allowed, the third is denied until the period passed
+		steps := []struct {
+			at      time.Duration
+			allowed bool
+		}{
+			{0, true},
+			{100 * time.Millisecond, true},
+			{200 * time.Millisecond, false},
+			{1200 * time.Millisecond, true},
+		}
+
+		for i, step := range steps {
+			result, err := store.Take(tc.name, tc.rate.withDefaults(), now.Add(step.at))
+			if err != nil {
+				t.Fatalf("%s: %s", tc.name, err.Error())
+			}
+
+			if result.Allowed != step.allowed {
+				t.Fatalf("%s: request %d allowed %v expected %v", tc.name, i, result.Allowed, step.allowed)
+			}
+
+			if !result.Allowed && result.RetryAfter <= 0 {
+				t.Fatalf("%s: request %d denied without retry after", tc.name, i)
+			}
+		}
+	}
+}
+
+// TestLimiter tests the limiter headers, keys and route overrides
+func TestLimiter(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(Limiter(&LimiterConfig{
+		Rate:   Rate{Algorithm: FixedWindow, Limit: 2, Period: time.Hour},
+		Routes: map[string]Rate{"/login": {Algorithm: FixedWindow, Limit: 1, Period: time.Hour}},
+		Key:    KeyByHeader("X-API-Key"),
+	}))
+	gz.Get("/ping", pingHandler)
+	gz.Get("/login", pingHandler)
+	startGodzilla(gz)
+
+	testCases := []struct {
+		path       string
+		key        string
+		statusCode int
+		headers    map[string]string
+	}{
+		{path: "/ping", key: "a", statusCode: StatusOK, headers: map[string]string{HeaderRateLimitLimit: "2", HeaderRateLimitRemaining: "1", HeaderRateLimitReset: "3600"}},
+		{path: "/ping", key: "a", statusCode: StatusOK, headers: map[string]string{HeaderRateLimitRemaining: "0"}},
+		{path: "/ping", key: "a", statusCode: StatusTooManyRequests, headers: map[string]string{HeaderRateLimitRemaining: "0", HeaderRetryAfter: "3600"}},
+		{path: "/ping", key: "b", statusCode: StatusOK},
+		{path: "/ping", key: "", statusCode: StatusOK, headers: map[string]string{HeaderRateLimitRemaining: "1"}},
+		{path: "/login", key: "a", statusCode: StatusOK, headers: map[string]string{HeaderRateLimitLimit: "1"}},
+		{path: "/login", key: "a", statusCode: StatusTooManyRequests},
+		{path: "/login", key: "b", statusCode: StatusOK},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, tc.path, nil)
+		req.Header.Set("X-API-Key", tc.key)
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s(%s): %s", tc.path, tc.key, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s(%s): returned %d expected %d", tc.path, tc.key, response.StatusCode, tc.statusCode)
+		}
+
+		for key, value := range tc.headers {
+			if actual := response.Header.Get(key); actual != value {
+				t.Fatalf("%s(%s): header %s returned %q expected %q", tc.path, tc.key, key, actual, value)
+			}
+		}
+	}
+}
+
+// TestLimiterLazyExpire tests the default store removes expired keys while
+// counting instead of running a goroutine
+func TestLimiterLazyExpire(t *testing.T) {
+	store := newMemoryStore(true)
+	rate := Rate{Algorithm: FixedWindow, Limit: 1, Period: time.Second}.withDefaults()
+	now := time.Now()
+
+	store.Take("a", rate, now)
+	store.Take("b", rate, now.Add(2*time.Minute))
+
+	shard := store.shard("a")
+	if _, ok := shard.entries["a"]; ok {
+		t.Fatal("expired key was not removed")
+	}
+}
@@ -0,0 +1,200 @@
+package godzilla
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// LogLevel is the severity of a log message, the values match log/slog levels
+type LogLevel int
+
+// Log levels
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+// String returns the upper case name of the level
+func (l LogLevel) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// LeveledLogger is the logger used by the framework and fasthttp, fields are
+// alternating keys and values, e.g. Info("server started", "addr", ":8080")
+type LeveledLogger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+
+	// With returns a logger adding fields to every message
+	With(fields ...interface{}) LeveledLogger
+}
+
+// NewTextLogger returns a logger writing "time LEVEL msg key=value" lines to
+// out, messages below level are dropped
+func NewTextLogger(out io.Writer, level LogLevel) LeveledLogger {
+	return &logger{output: &logOutput{w: out}, level: level, encode: encodeText}
+}
+
+// NewJSONLogger returns a logger writing one json object per message to out,
+// messages below level are dropped
+func NewJSONLogger(out io.Writer, level LogLevel) LeveledLogger {
+	return &logger{output: &logOutput{w: out}, level: level, encode: encodeJSON}
+}
+
+// logOutput serializes writes of loggers sharing a writer
+type logOutput struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+type logger struct {
+	output *logOutput
+	level  LogLevel
+	fields []interface{}
+	encode func(buf *bytes.Buffer, t time.Time, level LogLevel, msg string, fields []interface{})
+}
+
+func (l *logger) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields) }
+func (l *logger) Info(msg string, fields ...interface{})  { l.log(LevelInfo, msg, fields) }
+func (l *logger) Warn(msg string, fields ...interface{})  { l.log(LevelWarn, msg, fields) }
+func (l *logger) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields) }
+
+func (l *logger) With(fields ...interface{}) LeveledLogger {
+	child := *l
+	child.fields = append(append([]interface{}(nil), l.fields...), fields...)
+	return &child
+}
+
+func (l *logger) log(level LogLevel, msg string, fields []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	if len(l.fields) > 0 {
+		fields = append(append([]interface{}(nil), l.fields...), fields...)
+	}
+
+	buf := logBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	l.encode(buf, time.Now(), level, msg, fields)
+	buf.WriteByte('\n')
+
+	l.output.mutex.Lock()
+	l.output.w.Write(buf.Bytes())
+	l.output.mutex.Unlock()
+	logBufferPool.Put(buf)
+}
+
+// fieldPairs calls fn for every key and value of fields, a value without key
+// is reported under "!BADKEY" like log/slog does
+func fieldPairs(fields []interface{}, fn func(key string, value interface{})) {
+	for i := 0; i < len(fields); i++ {
+		key, ok := fields[i].(string)
+		if !ok || i == len(fields)-1 {
+			fn("!BADKEY", fields[i])
+			continue
+		}
+		fn(key, fields[i+1])
+		i++
+	}
+}
+
+func encodeText(buf *bytes.Buffer, t time.Time, level LogLevel, msg string, fields []interface{}) {
+	buf.WriteString(t.Format(time.RFC3339))
+	buf.WriteByte(' ')
+	buf.WriteString(level.String())
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+
+	fieldPairs(fields, func(key string, value interface{}) {
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+
+		s := fmt.Sprint(value)
+		if err, ok := value.(error); ok {
+			s = err.Error()
+		}
+
+		if needsQuoting(s) {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	})
+}
+
+func encodeJSON(buf *bytes.Buffer, t time.Time, level LogLevel, msg string, fields []interface{}) {
+	stream := jsoniter.ConfigCompatibleWithStandardLibrary.BorrowStream(buf)
+	defer jsoniter.ConfigCompatibleWithStandardLibrary.ReturnStream(stream)
+
+	stream.WriteObjectStart()
+	stream.WriteObjectField("time")
+	stream.WriteString(t.Format(time.RFC3339Nano))
+	stream.WriteMore()
+	stream.WriteObjectField("level")
+	stream.WriteString(level.String())
+	stream.WriteMore()
+	stream.WriteObjectField("msg")
+	stream.WriteString(msg)
+
+	fieldPairs(fields, func(key string, value interface{}) {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+
+		stream.WriteMore()
+		stream.WriteObjectField(key)
+		stream.WriteVal(value)
+	})
+
+	stream.WriteObjectEnd()
+	stream.Flush()
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r > '~' {
+			return true
+		}
+	}
+	return false
+}
+
+// nopLogger drops every message, it is used when Settings.Logger is nil
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...interface{})  {}
+func (nopLogger) Info(msg string, fields ...interface{})   {}
+func (nopLogger) Warn(msg string, fields ...interface{})   {}
+func (nopLogger) Error(msg string, fields ...interface{})  {}
+func (nopLogger) With(fields ...interface{}) LeveledLogger { return nopLogger{} }
+
+// logger returns the framework logger, a nop logger when none is set
+func (s *Settings) logger() LeveledLogger {
+	if s.Logger == nil {
+		return nopLogger{}
+	}
+	return s.Logger
+}
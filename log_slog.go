@@ -0,0 +1,31 @@
+//go:build go1.21
+// +build go1.21
+
+package godzilla
+
+import (
+	gocontext "context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a logger sending messages to a log/slog handler
+func NewSlogLogger(handler slog.Handler) LeveledLogger {
+	return &slogLogger{logger: slog.New(handler)}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields) }
+func (l *slogLogger) Info(msg string, fields ...interface{})  { l.log(LevelInfo, msg, fields) }
+func (l *slogLogger) Warn(msg string, fields ...interface{})  { l.log(LevelWarn, msg, fields) }
+func (l *slogLogger) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields) }
+
+func (l *slogLogger) With(fields ...interface{}) LeveledLogger {
+	return &slogLogger{logger: l.logger.With(fields...)}
+}
+
+func (l *slogLogger) log(level LogLevel, msg string, fields []interface{}) {
+	l.logger.Log(gocontext.Background(), slog.Level(level), msg, fields...)
+}
@@ -0,0 +1,25 @@
+//go:build go1.21
+// +build go1.21
+
+package godzilla
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestSlogLogger tests the log/slog adapter
+func TestSlogLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewSlogLogger(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	logger.Info("dropped")
+	logger.With("script", "hello.js").Warn("slow handler", "ms", 120)
+
+	expected := `level=WARN msg="slow handler" script=hello.js ms=120`
+	if !strings.HasSuffix(strings.TrimSpace(out.String()), expected) {
+		t.Fatalf("logged %q expected %q", out.String(), expected)
+	}
+}
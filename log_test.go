@@ -0,0 +1,67 @@
+package godzilla
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestLeveledLoggers tests the text and json loggers
+func TestLeveledLoggers(t *testing.T) {
+	testCases := []struct {
+		name   string
+		logger func(out *bytes.Buffer) LeveledLogger
+		lines  []string
+	}{
+		{
+			name:   "text",
+			logger: func(out *bytes.Buffer) LeveledLogger { return NewTextLogger(out, LevelInfo) },
+			lines: []string{
+				`INFO server started addr=:8080 name="Godzilla Framework"`,
+				`ERROR failed request_id=abc error="broken pipe" !BADKEY=dangling`,
+			},
+		},
+		{
+			name:   "json",
+			logger: func(out *bytes.Buffer) LeveledLogger { return NewJSONLogger(out, LevelInfo) },
+			lines: []string{
+				`"level":"INFO","msg":"server started","addr":":8080","name":"Godzilla Framework"}`,
+				`"level":"ERROR","msg":"failed","request_id":"abc","error":"broken pipe","!BADKEY":"dangling"}`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		var out bytes.Buffer
+		logger := tc.logger(&out)
+
+		logger.Debug("dropped")
+		logger.Info("server started", "addr", ":8080", "name", name)
+		logger.With("request_id", "abc").Error("failed", "error", errors.New("broken pipe"), "dangling")
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != len(tc.lines) {
+			t.Fatalf("%s: logged %q expected %d lines", tc.name, out.String(), len(tc.lines))
+		}
+
+		for i, line := range tc.lines {
+			if !strings.HasSuffix(lines[i], line) {
+				t.Fatalf("%s: logged %q expected it to end with %q", tc.name, lines[i], line)
+			}
+		}
+	}
+}
+
+// TestFasthttpLogger tests that fasthttp messages reach the framework logger
+func TestFasthttpLogger(t *testing.T) {
+	var out bytes.Buffer
+	gz := New(&Settings{Logger: NewTextLogger(&out, LevelInfo)}).(*godzilla)
+
+	gz.httpServer.Logger.Printf("error when serving connection %q: %s", "127.0.0.1", "timeout")
+
+	expected := `ERROR error when serving connection "127.0.0.1": timeout component=fasthttp`
+	if !strings.HasSuffix(strings.TrimSpace(out.String()), expected) {
+		t.Fatalf("logged %q expected %q", out.String(), expected)
+	}
+}
@@ -0,0 +1,385 @@
+package godzilla
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	interncolor "github.com/godzillaframework/godzilla/internal/internalcolor"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+// Access log formats
+const (
+	LogFormatDefault  = "default"  // colored console line
+	LogFormatCommon   = "common"   // Common Log Format
+	LogFormatCombined = "combined" // Combined Log Format
+	LogFormatJSON     = "json"     // one json object per line
+)
+
+// HeaderXRequestID is the header carrying the request id
+const HeaderXRequestID = "X-Request-ID"
+
+// LoggerConfig holds the access logger settings
+type LoggerConfig struct {
+	// Writer access log lines are written to, wrap it with NewAsyncWriter to
+	// keep writes off the request path
+	Output io.Writer // default os.Stdout
+
+	// One of the LogFormat constants, ignored when Template is set
+	Format string // default LogFormatDefault
+
+	// Custom line with ${tag} placeholders: time, method, path, route, status,
+	// bytes, latency, ip, user_agent, referer, protocol and request_id
+	Template string // default ""
+
+	// Paths that are not logged, a trailing * matches every path with that prefix
+	SkipPaths []string // default nil
+
+	// Requests for which Skip returns true are not logged
+	Skip func(ctx Context) bool // default nil
+
+	// Disable colors of the default format, NO_COLOR disables them too
+	DisableColors bool // default false
+}
+
+// accessEntry holds the fields of an access log line
+type accessEntry struct {
+	time      time.Time
+	method    string
+	path      string
+	uri       string // path with its query string
+	route     string
+	protocol  string
+	status    int
+	bytes     int
+	latency   time.Duration
+	ip        string
+	userAgent string
+	referer   string
+	requestID string
+}
+
+var logBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Logger returns a middleware writing an access log line for every request
+func Logger(config ...*LoggerConfig) handlerFunc {
+	cfg := &LoggerConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	output := cfg.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	format := writeDefaultLog(!cfg.DisableColors && os.Getenv("NO_COLOR") == "")
+	switch {
+	case cfg.Template != "":
+		format = compileLogTemplate(cfg.Template)
+	case cfg.Format == LogFormatCommon:
+		format = writeCommonLog
+	case cfg.Format == LogFormatCombined:
+		format = writeCombinedLog
+	case cfg.Format == LogFormatJSON:
+		format = writeJSONLog
+	}
+
+	return func(ctx Context) {
+		if skipPath(cfg.SkipPaths, GetString(ctx.Context().Path())) {
+			ctx.Next()
+			return
+		}
+
+		start := time.Now()
+		panicked := true
+
+		// deferred so a panicking handler is logged before the panic goes on
+		defer func() {
+			if cfg.Skip != nil && cfg.Skip(ctx) {
+				return
+			}
+
+			entry := newAccessEntry(ctx, start)
+			if panicked {
+				entry.status = fasthttp.StatusInternalServerError
+			}
+
+			buf := logBufferPool.Get().(*bytes.Buffer)
+			buf.Reset()
+			format(buf, entry)
+			buf.WriteByte('\n')
+			output.Write(buf.Bytes())
+			logBufferPool.Put(buf)
+		}()
+
+		ctx.Next()
+		panicked = false
+	}
+}
+
+func newAccessEntry(ctx Context, start time.Time) *accessEntry {
+	fctx := ctx.Context()
+
+	// a handler that timed out may still write to the response, see Timeout
+	resp := &fctx.Response
+	if timeout := fctx.LastTimeoutErrorResponse(); timeout != nil {
+		resp = timeout
+	}
+
+	entry := &accessEntry{
+		time:      start,
+		method:    GetString(fctx.Method()),
+		path:      GetString(fctx.Path()),
+		uri:       GetString(fctx.RequestURI()),
+		route:     ctx.Route(),
+		protocol:  GetString(fctx.Request.Header.Protocol()),
+		status:    resp.StatusCode(),
+		bytes:     resp.Header.ContentLength(),
+		latency:   time.Since(start),
+		ip:        ctx.IP(),
+		userAgent: GetString(fctx.UserAgent()),
+		referer:   GetString(fctx.Referer()),
+		requestID: ctx.RequestID(),
+	}
+
+	if entry.bytes < 0 || !resp.IsBodyStream() {
+		entry.bytes = len(resp.Body())
+	}
+
+	if entry.requestID == "" {
+		entry.requestID = GetString(resp.Header.Peek(HeaderXRequestID))
+	}
+	if entry.requestID == "" {
+		entry.requestID = ctx.Get(HeaderXRequestID)
+	}
+	return entry
+}
+
+// skipPath reports whether path matches one of paths
+func skipPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path || (strings.HasSuffix(p, "*") && strings.HasPrefix(path, p[:len(p)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDefaultLog writes "15:04:05 | 200 |   1.2ms | 127.0.0.1 | GET /path"
+func writeDefaultLog(colors bool) func(buf *bytes.Buffer, e *accessEntry) {
+	paint := func(msg string, color func(string) string) string {
+		if !colors {
+			return msg
+		}
+		return color(msg)
+	}
+
+	return func(buf *bytes.Buffer, e *accessEntry) {
+		status := strconv.Itoa(e.status)
+		switch {
+		case e.status >= 500:
+			status = paint(status, interncolor.Red)
+		case e.status >= 400:
+			status = paint(status, interncolor.Yellow)
+		case e.status >= 300:
+			status = paint(status, interncolor.Blue)
+		default:
+			status = paint(status, interncolor.Green)
+		}
+
+		buf.WriteString(paint(e.time.Format("15:04:05"), interncolor.Dim))
+		buf.WriteString(" | ")
+		buf.WriteString(status)
+		buf.WriteString(" | ")
+		buf.WriteString(padLeft(e.latency.String(), 12))
+		buf.WriteString(" | ")
+		buf.WriteString(padLeft(e.ip, 15))
+		buf.WriteString(" | ")
+		buf.WriteString(paint(e.method, interncolor.Pink))
+		buf.WriteByte(' ')
+		buf.WriteString(e.path)
+	}
+}
+
+// writeCommonLog writes `ip - - [time] "GET /path?query HTTP/1.1" status bytes`
+func writeCommonLog(buf *bytes.Buffer, e *accessEntry) {
+	buf.WriteString(e.ip)
+	buf.WriteString(" - - [")
+	buf.WriteString(e.time.Format("02/Jan/2006:15:04:05 -0700"))
+	buf.WriteString(`] "`)
+	buf.WriteString(e.method)
+	buf.WriteByte(' ')
+	buf.WriteString(e.uri)
+	buf.WriteByte(' ')
+	buf.WriteString(e.protocol)
+	buf.WriteString(`" `)
+	buf.WriteString(strconv.Itoa(e.status))
+	buf.WriteByte(' ')
+	if e.bytes > 0 {
+		buf.WriteString(strconv.Itoa(e.bytes))
+	} else {
+		buf.WriteByte('-')
+	}
+}
+
+// writeCombinedLog writes the common log format followed by referer and user agent
+func writeCombinedLog(buf *bytes.Buffer, e *accessEntry) {
+	writeCommonLog(buf, e)
+	buf.WriteString(` "`)
+	buf.WriteString(e.referer)
+	buf.WriteString(`" "`)
+	buf.WriteString(e.userAgent)
+	buf.WriteByte('"')
+}
+
+// writeJSONLog writes the entry as a json object
+func writeJSONLog(buf *bytes.Buffer, e *accessEntry) {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	json.NewEncoder(buf).Encode(map[string]interface{}{
+		"time":       e.time.Format(time.RFC3339Nano),
+		"method":     e.method,
+		"path":       e.path,
+		"route":      e.route,
+		"protocol":   e.protocol,
+		"status":     e.status,
+		"bytes":      e.bytes,
+		"latency":    e.latency.Seconds(),
+		"ip":         e.ip,
+		"user_agent": e.userAgent,
+		"referer":    e.referer,
+		"request_id": e.requestID,
+	})
+
+	// Encode terminates the object with a newline, the logger adds its own
+	buf.Truncate(buf.Len() - 1)
+}
+
+// compileLogTemplate splits template into literals and ${tag} placeholders once
+func compileLogTemplate(template string) func(buf *bytes.Buffer, e *accessEntry) {
+	var parts []func(buf *bytes.Buffer, e *accessEntry)
+
+	for template != "" {
+		start := strings.Index(template, "${")
+		end := -1
+		if start >= 0 {
+			end = strings.IndexByte(template[start:], '}') + start
+		}
+		if start < 0 || end < start {
+			literal := template
+			parts = append(parts, func(buf *bytes.Buffer, e *accessEntry) { buf.WriteString(literal) })
+			break
+		}
+
+		literal, tag := template[:start], template[start+2:end]
+		template = template[end+1:]
+
+		if literal != "" {
+			parts = append(parts, func(buf *bytes.Buffer, e *accessEntry) { buf.WriteString(literal) })
+		}
+		parts = append(parts, logTag(tag))
+	}
+
+	return func(buf *bytes.Buffer, e *accessEntry) {
+		for _, part := range parts {
+			part(buf, e)
+		}
+	}
+}
+
+// logTag returns the writer of a template tag, unknown tags are kept as is
+func logTag(tag string) func(buf *bytes.Buffer, e *accessEntry) {
+	value := map[string]func(e *accessEntry) string{
+		"time":       func(e *accessEntry) string { return e.time.Format(time.RFC3339) },
+		"method":     func(e *accessEntry) string { return e.method },
+		"path":       func(e *accessEntry) string { return e.path },
+		"route":      func(e *accessEntry) string { return e.route },
+		"protocol":   func(e *accessEntry) string { return e.protocol },
+		"status":     func(e *accessEntry) string { return strconv.Itoa(e.status) },
+		"bytes":      func(e *accessEntry) string { return strconv.Itoa(e.bytes) },
+		"latency":    func(e *accessEntry) string { return e.latency.String() },
+		"ip":         func(e *accessEntry) string { return e.ip },
+		"user_agent": func(e *accessEntry) string { return e.userAgent },
+		"referer":    func(e *accessEntry) string { return e.referer },
+		"request_id": func(e *accessEntry) string { return e.requestID },
+	}[tag]
+
+	if value == nil {
+		return func(buf *bytes.Buffer, e *accessEntry) { buf.WriteString("${" + tag + "}") }
+	}
+	return func(buf *bytes.Buffer, e *accessEntry) { buf.WriteString(value(e)) }
+}
+
+func padLeft(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat(" ", width-len(s)) + s
+}
+
+// AsyncWriter buffers writes in memory and writes them to the underlying
+// writer from its own goroutine, so slow outputs do not delay requests
+type AsyncWriter struct {
+	out     io.Writer
+	lines   chan []byte
+	done    chan struct{}
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// NewAsyncWriter returns a writer buffering up to size writes for out
+func NewAsyncWriter(out io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = 1024
+	}
+
+	w := &AsyncWriter{
+		out:   out,
+		lines: make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+		for line := range w.lines {
+			w.out.Write(line)
+		}
+	}()
+	return w
+}
+
+// Write queues a copy of p, it blocks only while the buffer is full
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	w.lines <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+// Close flushes the queued writes and stops the writer
+func (w *AsyncWriter) Close() error {
+	w.closeMu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.lines)
+	}
+	w.closeMu.Unlock()
+
+	<-w.done
+	return nil
+}
@@ -0,0 +1,96 @@
+package godzilla
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestLogger tests the access log formats and skip rules
+func TestLogger(t *testing.T) {
+	testCases := []struct {
+		config *LoggerConfig
+		path   string
+		line   string
+	}{
+		{config: &LoggerConfig{Template: "${method} ${path} ${route} ${status} ${bytes} ${request_id} ${unknown}"}, path: "/users/42", line: "GET /users/42 /users/:id 200 4 abc ${unknown}\n"},
+		{config: &LoggerConfig{Format: LogFormatCommon}, path: "/users/42?page=2", line: `"GET /users/42?page=2 HTTP/1.1" 200 4` + "\n"},
+		{config: &LoggerConfig{Format: LogFormatCombined}, path: "/users/42?page=2", line: `"GET /users/42?page=2 HTTP/1.1" 200 4 "" "test"` + "\n"},
+		{config: &LoggerConfig{Format: LogFormatJSON}, path: "/users/42", line: `"route":"/users/:id"`},
+		{config: &LoggerConfig{DisableColors: true}, path: "/users/42", line: "| GET /users/42\n"},
+		{config: &LoggerConfig{SkipPaths: []string{"/users/*"}}, path: "/users/42"},
+		{config: &LoggerConfig{Skip: func(ctx Context) bool { return ctx.Route() == "/users/:id" }}, path: "/users/42"},
+	}
+
+	for _, tc := range testCases {
+		var out bytes.Buffer
+		tc.config.Output = &out
+
+		gz := setupGodzilla()
+		gz.Use(Logger(tc.config))
+		gz.Get("/users/:id", pingHandler)
+		startGodzilla(gz)
+
+		req, _ := http.NewRequest(MethodGet, tc.path, nil)
+		req.Header.Set(HeaderXRequestID, "abc")
+		req.Header.Set("User-Agent", "test")
+
+		if _, err := makeRequest(req, gz); err != nil {
+			t.Fatalf("%s: %s", tc.path, err.Error())
+		}
+
+		if tc.line == "" {
+			if out.Len() != 0 {
+				t.Fatalf("%s: logged %q expected nothing", tc.path, out.String())
+			}
+			continue
+		}
+
+		if !strings.Contains(out.String(), tc.line) {
+			t.Fatalf("%s: logged %q expected it to contain %q", tc.path, out.String(), tc.line)
+		}
+	}
+}
+
+// TestLoggerPanic tests a panicking handler is logged before the panic is recovered
+func TestLoggerPanic(t *testing.T) {
+	var out bytes.Buffer
+
+	gz := setupGodzilla(&Settings{AutoRecover: true, Logger: NewTextLogger(io.Discard, LevelInfo)})
+	gz.Use(Logger(&LoggerConfig{Output: &out, Template: "${status} ${path}"}))
+	gz.Get("/panic", func(ctx Context) {
+		panic("boom")
+	})
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodGet, "/panic", nil)
+	response, err := makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != StatusInternalServerError || out.String() != "500 /panic\n" {
+		t.Fatalf("returned %d and logged %q expected a 500 line", response.StatusCode, out.String())
+	}
+}
+
+// TestAsyncWriter tests that closing the writer flushes queued writes
+func TestAsyncWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewAsyncWriter(&out, 2)
+
+	for i := 0; i < 10; i++ {
+		w.Write([]byte("line\n"))
+	}
+	w.Close()
+
+	if got := strings.Count(out.String(), "line\n"); got != 10 {
+		t.Fatalf("wrote %d lines expected 10", got)
+	}
+
+	if _, err := w.Write([]byte("line\n")); err == nil {
+		t.Fatalf("write after close returned no error")
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"hash/crc32"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// virtualNodes is the number of points of every upstream on the hash ring,
+// more points spread keys more evenly
+const virtualNodes = 128
+
+// upstream is a server requests are forwarded to
+type upstream struct {
+	active int64 // requests in flight, first for 64-bit alignment of atomics
+
+	index  int
+	host   string
+	client *fasthttp.HostClient
+
+	mutex        sync.Mutex
+	fails        int       // consecutive failures
+	ejectedUntil time.Time // zero when the upstream is healthy
+}
+
+func newUpstream(u *url.URL, timeout time.Duration) *upstream {
+	addr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	return &upstream{
+		host: u.Host,
+		client: &fasthttp.HostClient{
+			Addr:                          addr,
+			IsTLS:                         u.Scheme == "https",
+			ReadTimeout:                   timeout,
+			WriteTimeout:                  timeout,
+			DisableHeaderNamesNormalizing: true,
+			DisablePathNormalizing:        true,
+			NoDefaultUserAgentHeader:      true,
+
+			// a second attempt covers keep-alive connections closed by the
+			// upstream, further retries go to other upstreams
+			MaxIdemponentCallAttempts: 2,
+		},
+	}
+}
+
+// available reports whether the upstream is not ejected
+func (u *upstream) available(now time.Time) bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return !now.Before(u.ejectedUntil)
+}
+
+// ejectionEnd returns when the upstream becomes available again
+func (u *upstream) ejectionEnd() time.Time {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.ejectedUntil
+}
+
+// report records the outcome of a request, maxFails consecutive failures
+// eject the upstream for ejectDuration, a success ends the ejection
+func (u *upstream) report(failed bool, maxFails int, ejectDuration time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !failed {
+		u.fails = 0
+		u.ejectedUntil = time.Time{}
+		return
+	}
+
+	u.fails++
+	if u.fails >= maxFails {
+		u.fails = 0
+		u.ejectedUntil = time.Now().Add(ejectDuration)
+	}
+}
+
+// pool balances requests across upstreams
+type pool struct {
+	next uint64 // round robin counter, first for 64-bit alignment of atomics
+
+	balancing     Balancing
+	timeout       time.Duration
+	maxFails      int
+	ejectDuration time.Duration
+
+	upstreams []*upstream
+
+	ring       []uint32 // sorted points of the hash ring
+	ringOwners map[uint32]*upstream
+}
+
+func newPool(balancing Balancing, timeout time.Duration, maxFails int, ejectDuration time.Duration) *pool {
+	return &pool{
+		balancing:     balancing,
+		timeout:       timeout,
+		maxFails:      maxFails,
+		ejectDuration: ejectDuration,
+	}
+}
+
+func (p *pool) add(u *upstream) {
+	u.index = len(p.upstreams)
+	p.upstreams = append(p.upstreams, u)
+}
+
+// build places the upstreams on the hash ring
+func (p *pool) build() {
+	if p.balancing != ConsistentHash {
+		return
+	}
+
+	p.ringOwners = make(map[uint32]*upstream, len(p.upstreams)*virtualNodes)
+	for _, u := range p.upstreams {
+		for i := 0; i < virtualNodes; i++ {
+			point := crc32.ChecksumIEEE([]byte(u.client.Addr + "#" + strconv.Itoa(i)))
+			if _, taken := p.ringOwners[point]; taken {
+				continue
+			}
+			p.ringOwners[point] = u
+			p.ring = append(p.ring, point)
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i] < p.ring[j] })
+}
+
+// pick returns an available upstream that was not tried yet, nil when there
+// is none
+func (p *pool) pick(key string, tried []bool) *upstream {
+	now := time.Now()
+	usable := func(u *upstream) bool {
+		return !tried[u.index] && u.available(now)
+	}
+
+	switch p.balancing {
+	case ConsistentHash:
+		// walk the ring clockwise from the point of key
+		hash := crc32.ChecksumIEEE([]byte(key))
+		start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i] >= hash })
+		for i := 0; i < len(p.ring); i++ {
+			if u := p.ringOwners[p.ring[(start+i)%len(p.ring)]]; usable(u) {
+				return u
+			}
+		}
+		return nil
+
+	case LeastConnections:
+		// ties are broken in turns
+		offset := int(atomic.AddUint64(&p.next, 1) % uint64(len(p.upstreams)))
+		var best *upstream
+		for i := range p.upstreams {
+			u := p.upstreams[(offset+i)%len(p.upstreams)]
+			if usable(u) && (best == nil || atomic.LoadInt64(&u.active) < atomic.LoadInt64(&best.active)) {
+				best = u
+			}
+		}
+		return best
+
+	default:
+		offset := int((atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.upstreams)))
+		for i := range p.upstreams {
+			if u := p.upstreams[(offset+i)%len(p.upstreams)]; usable(u) {
+				return u
+			}
+		}
+		return nil
+	}
+}
+
+// recovering returns the upstream whose ejection ends first
+func (p *pool) recovering() *upstream {
+	var best *upstream
+	var end time.Time
+	for _, u := range p.upstreams {
+		if until := u.ejectionEnd(); best == nil || until.Before(end) {
+			best, end = u, until
+		}
+	}
+	return best
+}
+
+// do forwards req to u, 502, 503 and 504 responses return ErrUpstreamStatus
+func (p *pool) do(u *upstream, req *fasthttp.Request, resp *fasthttp.Response) error {
+	atomic.AddInt64(&u.active, 1)
+	err := u.client.DoTimeout(req, resp, p.timeout)
+	atomic.AddInt64(&u.active, -1)
+
+	if err == nil {
+		switch resp.StatusCode() {
+		case fasthttp.StatusBadGateway, fasthttp.StatusServiceUnavailable, fasthttp.StatusGatewayTimeout:
+			err = ErrUpstreamStatus
+		}
+	}
+
+	u.report(err != nil, p.maxFails, p.ejectDuration)
+	return err
+}
@@ -0,0 +1,324 @@
+/*
+Package proxy forwards requests to upstream servers, balancing them across
+healthy upstreams and retrying idempotent requests.
+
+	gz.Get("/legacy/*", proxy.Handler([]string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}, &proxy.Options{
+		Balancing:   proxy.LeastConnections,
+		StripPrefix: "/legacy",
+	}))
+*/
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/godzillaframework/godzilla"
+	"github.com/valyala/fasthttp"
+)
+
+// Balancing selects the upstream of a request
+type Balancing int
+
+// Balancing algorithms
+const (
+	RoundRobin       Balancing = iota // upstreams take turns
+	LeastConnections                  // the upstream with the fewest requests in flight
+	ConsistentHash                    // requests with the same Options.HashKey go to the same upstream
+)
+
+var (
+	// ErrNoUpstream is passed to Options.ErrorHandler when no upstream could
+	// be tried, requests go to the first ejected upstream to recover when
+	// every upstream is ejected
+	ErrNoUpstream = errors.New("proxy: no healthy upstream")
+
+	// ErrUpstreamStatus is passed to Options.ErrorHandler when the last attempt
+	// was answered with a status counted as failure, e.g. 503
+	ErrUpstreamStatus = errors.New("proxy: upstream failed")
+)
+
+// hopHeaders are meaningful for a single connection and are not forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Options holds the proxy settings
+type Options struct {
+	Balancing Balancing // default RoundRobin
+
+	// Returns the key of ConsistentHash balancing
+	HashKey func(ctx godzilla.Context) string // default ctx.IP()
+
+	// Prefix removed from request paths, e.g. "/api" forwards "/api/users" as "/users"
+	StripPrefix string // default ""
+
+	// Keep the Host header of the client instead of the upstream host
+	PreserveHost bool // default false
+
+	// Headers set on or removed from forwarded requests
+	RequestHeaders       map[string]string // default nil
+	RemoveRequestHeaders []string          // default nil
+
+	// Headers set on or removed from responses sent to the client
+	ResponseHeaders       map[string]string // default nil
+	RemoveResponseHeaders []string          // default nil
+
+	// Called before every attempt with a fresh copy of the request sent upstream
+	ModifyRequest func(ctx godzilla.Context, req *fasthttp.Request) // default nil
+
+	// Called with the response of the upstream before it is sent to the
+	// client, an error is passed to ErrorHandler
+	ModifyResponse func(ctx godzilla.Context, resp *fasthttp.Response) error // default nil
+
+	// Maximum duration of an attempt
+	Timeout time.Duration // default 30 seconds
+
+	// Attempts made on other upstreams after a failure, only for idempotent methods
+	Retries int // default 2, negative disables retries
+
+	// Consecutive failures ejecting an upstream, failures are transport errors
+	// and 502, 503 and 504 responses
+	MaxFails int // default 3
+
+	// Duration an upstream stays ejected, unless every upstream is
+	EjectDuration time.Duration // default 30 seconds
+
+	// Answers requests that could not be forwarded
+	ErrorHandler func(ctx godzilla.Context, err error) // default 502 Bad Gateway, 504 Gateway Timeout on timeouts
+}
+
+// Handler returns a handler forwarding requests to upstreams, e.g.
+// "http://10.0.0.1:8080" or "https://legacy.internal". It panics when an
+// upstream is not a valid url.
+func Handler(upstreams []string, opts ...*Options) func(ctx godzilla.Context) {
+	cfg := &Options{}
+	if len(opts) > 0 {
+		cfg = opts[0]
+	}
+
+	if len(upstreams) == 0 {
+		panic("proxy: no upstreams")
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	retries := cfg.Retries
+	if retries == 0 {
+		retries = 2
+	} else if retries < 0 {
+		retries = 0
+	}
+
+	maxFails := cfg.MaxFails
+	if maxFails <= 0 {
+		maxFails = 3
+	}
+
+	ejectDuration := cfg.EjectDuration
+	if ejectDuration <= 0 {
+		ejectDuration = 30 * time.Second
+	}
+
+	hashKey := cfg.HashKey
+	if hashKey == nil {
+		hashKey = func(ctx godzilla.Context) string {
+			return ctx.IP()
+		}
+	}
+
+	errorHandler := cfg.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = defaultErrorHandler
+	}
+
+	pool := newPool(cfg.Balancing, timeout, maxFails, ejectDuration)
+	for _, raw := range upstreams {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			panic("proxy: invalid upstream '" + raw + "'")
+		}
+		pool.add(newUpstream(u, timeout))
+	}
+	pool.build()
+
+	return func(ctx godzilla.Context) {
+		fctx := ctx.Context()
+
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+
+		attempts := 1
+		if idempotent(string(fctx.Method())) {
+			attempts += retries
+		}
+
+		var key string
+		if cfg.Balancing == ConsistentHash {
+			key = hashKey(ctx)
+		}
+
+		var err error
+		tried := make([]bool, len(pool.upstreams))
+		for i := 0; i < attempts; i++ {
+			u := pool.pick(key, tried)
+			if u == nil && i == 0 {
+				// every upstream is ejected, the first to recover is the best bet
+				u = pool.recovering()
+			}
+			if u == nil {
+				if err == nil {
+					err = ErrNoUpstream
+				}
+				break
+			}
+			tried[u.index] = true
+
+			// every attempt starts from the client request, ModifyRequest
+			// does not see the changes of the previous attempt
+			outgoing(ctx, req, cfg)
+
+			// requests are written with the host of their uri
+			if !cfg.PreserveHost {
+				req.URI().SetHost(u.host)
+				req.Header.SetHost(u.host)
+			}
+			if cfg.ModifyRequest != nil {
+				cfg.ModifyRequest(ctx, req)
+			}
+
+			resp.Reset()
+			err = pool.do(u, req, resp)
+			if err == nil {
+				break
+			}
+		}
+
+		if err != nil && err != ErrUpstreamStatus {
+			errorHandler(ctx, err)
+			return
+		}
+
+		// the last failed response is passed on when retries ran out
+		if err := incoming(ctx, resp, cfg); err != nil {
+			errorHandler(ctx, err)
+		}
+	}
+}
+
+// outgoing prepares the request sent upstream from the client request
+func outgoing(ctx godzilla.Context, req *fasthttp.Request, cfg *Options) {
+	fctx := ctx.Context()
+	fctx.Request.CopyTo(req)
+
+	uri := string(fctx.Request.RequestURI())
+	if cfg.StripPrefix != "" && strings.HasPrefix(uri, cfg.StripPrefix) {
+		rest := uri[len(cfg.StripPrefix):]
+		if rest == "" || rest[0] == '?' {
+			rest = "/" + rest
+		}
+		if rest[0] == '/' {
+			uri = rest
+		}
+	}
+	req.SetRequestURI(uri)
+	req.URI().DisablePathNormalizing = true
+
+	removeHopHeaders(&req.Header)
+
+	// the peer of the connection is appended, trust is up to the upstream
+	peer := fctx.RemoteIP().String()
+	if prior := string(req.Header.Peek(godzilla.HeaderXForwardedFor)); prior != "" {
+		peer = prior + ", " + peer
+	}
+	req.Header.Set(godzilla.HeaderXForwardedFor, peer)
+	req.Header.Set(godzilla.HeaderXForwardedProto, ctx.Scheme())
+	req.Header.Set(godzilla.HeaderXForwardedHost, ctx.Host())
+
+	if id := ctx.RequestID(); id != "" {
+		req.Header.Set(godzilla.HeaderXRequestID, id)
+	}
+
+	for _, name := range cfg.RemoveRequestHeaders {
+		req.Header.Del(name)
+	}
+	for name, value := range cfg.RequestHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+// incoming copies the upstream response to the client response
+func incoming(ctx godzilla.Context, resp *fasthttp.Response, cfg *Options) error {
+	removeHopHeaders(&resp.Header)
+
+	for _, name := range cfg.RemoveResponseHeaders {
+		resp.Header.Del(name)
+	}
+	for name, value := range cfg.ResponseHeaders {
+		resp.Header.Set(name, value)
+	}
+
+	if cfg.ModifyResponse != nil {
+		if err := cfg.ModifyResponse(ctx, resp); err != nil {
+			return err
+		}
+	}
+
+	resp.CopyTo(&ctx.Context().Response)
+	return nil
+}
+
+// headers is implemented by request and response headers
+type headers interface {
+	Peek(key string) []byte
+	Del(key string)
+}
+
+// removeHopHeaders removes the hop-by-hop headers, including the ones listed
+// in Connection
+func removeHopHeaders(h headers) {
+	for _, name := range strings.Split(string(h.Peek("Connection")), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			h.Del(name)
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// idempotent reports whether requests of method can be safely repeated
+func idempotent(method string) bool {
+	switch method {
+	case godzilla.MethodGet, godzilla.MethodHead, godzilla.MethodOptions,
+		godzilla.MethodTrace, godzilla.MethodPut, godzilla.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func defaultErrorHandler(ctx godzilla.Context, err error) {
+	code := fasthttp.StatusBadGateway
+	if err == ErrNoUpstream {
+		code = fasthttp.StatusServiceUnavailable
+	} else if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		code = fasthttp.StatusGatewayTimeout
+	}
+	ctx.Context().Error(fasthttp.StatusMessage(code), code)
+}
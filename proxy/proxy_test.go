@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godzillaframework/godzilla"
+	"github.com/valyala/fasthttp"
+)
+
+// startUpstream starts a server answering with its name, the request uri and
+// forwarding headers
+func startUpstream(t *testing.T, name string) (string, func()) {
+	return startServer(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("Connection", "X-Internal")
+		ctx.Response.Header.Set("X-Internal", "secret")
+		ctx.SetBodyString(name + " " + string(ctx.RequestURI()) + " " +
+			string(ctx.Request.Header.Peek(godzilla.HeaderXForwardedFor)) + " " +
+			string(ctx.Request.Header.Peek(godzilla.HeaderXForwardedHost)) + " " +
+			string(ctx.Host()))
+	})
+}
+
+// startServer starts a server answering with handler
+func startServer(t *testing.T, handler fasthttp.RequestHandler) (string, func()) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// idle connections of the proxy would hold up Shutdown
+	server := &fasthttp.Server{IdleTimeout: 50 * time.Millisecond, Handler: handler}
+	go server.Serve(ln)
+
+	var once sync.Once
+	return "http://" + ln.Addr().String(), func() {
+		once.Do(func() { server.Shutdown() })
+	}
+}
+
+// startProxy serves handler on addr
+func startProxy(t *testing.T, addr string, handler func(ctx godzilla.Context)) godzilla.Godzilla {
+	gz := godzilla.New(&godzilla.Settings{DisableStartupMessage: true})
+	gz.Get("/api/*", handler)
+	gz.Post("/api/*", handler)
+	go gz.Start(addr)
+
+	for i := 0; i < 100; i++ {
+		if c, err := net.Dial("tcp4", addr); err == nil {
+			c.Close()
+			return gz
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("proxy did not start on %s", addr)
+	return nil
+}
+
+func request(t *testing.T, method, url string) (int, string, *fasthttp.ResponseHeader) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(method)
+	req.SetRequestURI(url)
+	req.SetConnectionClose()
+	if err := fasthttp.DoTimeout(req, resp, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	header := &fasthttp.ResponseHeader{}
+	resp.Header.CopyTo(header)
+	return resp.StatusCode(), string(resp.Body()), header
+}
+
+// TestHandler tests forwarding, balancing, ejecting and retrying
+func TestHandler(t *testing.T) {
+	a, stopA := startUpstream(t, "a")
+	defer stopA()
+	b, stopB := startUpstream(t, "b")
+	defer stopB()
+
+	gz := startProxy(t, "127.0.0.1:3071", Handler([]string{a, b}, &Options{
+		StripPrefix:     "/api",
+		ResponseHeaders: map[string]string{"X-Proxy": "godzilla"},
+		MaxFails:        1,
+	}))
+	defer gz.Stop()
+
+	// requests are forwarded without the prefix and hop-by-hop headers
+	status, body, header := request(t, godzilla.MethodGet, "http://127.0.0.1:3071/api/users?page=2")
+	fields := strings.Fields(body)
+	if status != godzilla.StatusOK || len(fields) != 5 || fields[1] != "/users?page=2" ||
+		fields[2] != "127.0.0.1" || fields[3] != "127.0.0.1:3071" || ("http://"+fields[4] != a && "http://"+fields[4] != b) {
+		t.Fatalf("returned %d %q", status, body)
+	}
+	if string(header.Peek("X-Proxy")) != "godzilla" || len(header.Peek("X-Internal")) != 0 {
+		t.Fatalf("returned headers %s", header.Header())
+	}
+
+	// upstreams take turns
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		_, body, _ := request(t, godzilla.MethodGet, "http://127.0.0.1:3071/api/users")
+		seen[strings.Fields(body)[0]]++
+	}
+	if seen["a"] != 2 || seen["b"] != 2 {
+		t.Fatalf("balanced %v", seen)
+	}
+
+	// idempotent requests are retried on the healthy upstream, which is the
+	// only one left once the failing upstream is ejected
+	stopB()
+	for i := 0; i < 4; i++ {
+		status, body, _ := request(t, godzilla.MethodGet, "http://127.0.0.1:3071/api/users")
+		if status != godzilla.StatusOK || !strings.HasPrefix(body, "a ") {
+			t.Fatalf("returned %d %q", status, body)
+		}
+	}
+
+	stopA()
+	status, _, _ = request(t, godzilla.MethodPost, "http://127.0.0.1:3071/api/users")
+	if status != godzilla.StatusBadGateway && status != godzilla.StatusServiceUnavailable {
+		t.Fatalf("returned %d with every upstream down", status)
+	}
+}
+
+// TestConsistentHash tests requests with the same key stick to an upstream
+func TestConsistentHash(t *testing.T) {
+	var upstreams []string
+	for _, name := range []string{"a", "b", "c"} {
+		addr, stop := startUpstream(t, name)
+		defer stop()
+		upstreams = append(upstreams, addr)
+	}
+
+	gz := startProxy(t, "127.0.0.1:3072", Handler(upstreams, &Options{
+		Balancing: ConsistentHash,
+		HashKey: func(ctx godzilla.Context) string {
+			return ctx.Query("user")
+		},
+	}))
+	defer gz.Stop()
+
+	owners := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		for _, user := range []string{"ann", "bob", "cid", "dan", "eve"} {
+			_, body, _ := request(t, godzilla.MethodGet, "http://127.0.0.1:3072/api/users?user="+user)
+			owner := strings.Fields(body)[0]
+			if prior, ok := owners[user]; ok && prior != owner {
+				t.Fatalf("user %s moved from %s to %s", user, prior, owner)
+			}
+			owners[user] = owner
+		}
+	}
+}
+
+// TestHandlerAttempts tests every attempt starts from the client request and
+// requests go to an ejected upstream when every upstream is ejected
+func TestHandlerAttempts(t *testing.T) {
+	attempts := func(ctx *fasthttp.RequestCtx) string {
+		n := 0
+		ctx.Request.Header.VisitAll(func(key, value []byte) {
+			if string(key) == "X-Attempt" {
+				n++
+			}
+		})
+		return strconv.Itoa(n)
+	}
+
+	var mutex sync.Mutex
+	failing := true
+	flaky, stopFlaky := startServer(t, func(ctx *fasthttp.RequestCtx) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if failing {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			return
+		}
+		ctx.SetBodyString("flaky " + attempts(ctx))
+	})
+	defer stopFlaky()
+	healthy, stopHealthy := startServer(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("healthy " + attempts(ctx))
+	})
+
+	gz := startProxy(t, "127.0.0.1:3073", Handler([]string{flaky, healthy}, &Options{
+		MaxFails:      1,
+		EjectDuration: time.Hour,
+		ModifyRequest: func(ctx godzilla.Context, req *fasthttp.Request) {
+			req.Header.Add("X-Attempt", "1")
+		},
+	}))
+	defer gz.Stop()
+
+	// the retry on the healthy upstream carries a single X-Attempt header
+	for i := 0; i < 2; i++ {
+		status, body, _ := request(t, godzilla.MethodGet, "http://127.0.0.1:3073/api/users")
+		if status != godzilla.StatusOK || body != "healthy 1" {
+			t.Fatalf("returned %d %q expected healthy 1", status, body)
+		}
+	}
+
+	// the flaky upstream recovered while both are ejected
+	stopHealthy()
+	request(t, godzilla.MethodGet, "http://127.0.0.1:3073/api/users")
+
+	mutex.Lock()
+	failing = false
+	mutex.Unlock()
+
+	status, body, _ := request(t, godzilla.MethodGet, "http://127.0.0.1:3073/api/users")
+	if status != godzilla.StatusOK || body != "flaky 1" {
+		t.Fatalf("returned %d %q with every upstream ejected", status, body)
+	}
+}
@@ -0,0 +1,219 @@
+package godzilla
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout bounds the time trusted proxies have to send the header
+const proxyHeaderTimeout = 5 * time.Second
+
+var (
+	proxyV1Signature = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyHeader = errors.New("invalid proxy protocol header")
+)
+
+// wrapListener reads PROXY protocol headers of trusted peers when
+// Settings.ProxyProtocol is set
+func (gz *godzilla) wrapListener(ln net.Listener) net.Listener {
+	if !gz.settings.ProxyProtocol {
+		return ln
+	}
+	return &proxyListener{
+		Listener:    ln,
+		trusted:     gz.router.trustedProxies,
+		readTimeout: gz.settings.ReadTimeout,
+	}
+}
+
+// proxyListener wraps the connections of trusted peers in proxyConns
+type proxyListener struct {
+	net.Listener
+	trusted     ipRanges
+	readTimeout time.Duration
+}
+
+func (ln *proxyListener) Accept() (net.Conn, error) {
+	c, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := c.RemoteAddr().(*net.TCPAddr)
+	if !ok || !ln.trusted.contains(addr.IP) {
+		return c, nil
+	}
+	return &proxyConn{Conn: c, readTimeout: ln.readTimeout}, nil
+}
+
+// proxyConn reads the PROXY protocol header on first use, so slow proxies do
+// not block Accept, connections without a header are served as is
+type proxyConn struct {
+	net.Conn
+	readTimeout time.Duration
+
+	once   sync.Once
+	reader *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+	err    error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.reader = bufio.NewReader(c.Conn)
+
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.local, c.err = readProxyHeader(c.reader)
+
+		// the deadline set by the server for the first request was overwritten
+		var deadline time.Time
+		if c.readTimeout > 0 {
+			deadline = time.Now().Add(c.readTimeout)
+		}
+		_ = c.Conn.SetReadDeadline(deadline)
+	})
+}
+
+// NetConn returns the connection of the proxy, e.g. to watch it, see Ctx
+func (c *proxyConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readProxyHeader reads a PROXY protocol v1 or v2 header, the addresses are nil
+// when there is none or when it does not carry tcp addresses
+func readProxyHeader(r *bufio.Reader) (remote, local net.Addr, err error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	switch first[0] {
+	case proxyV1Signature[0]:
+		if sig, err := r.Peek(len(proxyV1Signature)); err == nil && bytes.Equal(sig, proxyV1Signature) {
+			return readProxyV1(r)
+		}
+	case proxyV2Signature[0]:
+		if sig, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(sig, proxyV2Signature) {
+			return readProxyV2(r)
+		}
+	}
+	return nil, nil, nil
+}
+
+// readProxyV1 reads a text header, e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"
+func readProxyV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	// the header is at most 107 bytes long
+	var line []byte
+	for len(line) < 107 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errProxyHeader
+	}
+
+	src, dst := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, srcErr := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, dstErr := strconv.ParseUint(fields[5], 10, 16)
+	if src == nil || dst == nil || srcErr != nil || dstErr != nil {
+		return nil, nil, errProxyHeader
+	}
+
+	return &net.TCPAddr{IP: src, Port: int(srcPort)}, &net.TCPAddr{IP: dst, Port: int(dstPort)}, nil
+}
+
+// readProxyV2 reads a binary header, tlvs following the addresses are skipped
+func readProxyV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, nil, errProxyHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch command := header[12] & 0x0f; command {
+	case 0x0: // LOCAL, e.g. health checks of the proxy itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, errProxyHeader
+	}
+
+	var size int
+	switch header[13] >> 4 {
+	case 0x1: // AF_INET
+		size = net.IPv4len
+	case 0x2: // AF_INET6
+		size = net.IPv6len
+	default: // AF_UNSPEC and AF_UNIX carry no ip
+		return nil, nil, nil
+	}
+
+	if len(payload) < 2*size+4 {
+		return nil, nil, errProxyHeader
+	}
+
+	src := net.IP(payload[:size])
+	dst := net.IP(payload[size : 2*size])
+	srcPort := binary.BigEndian.Uint16(payload[2*size:])
+	dstPort := binary.BigEndian.Uint16(payload[2*size+2:])
+
+	return &net.TCPAddr{IP: src, Port: int(srcPort)}, &net.TCPAddr{IP: dst, Port: int(dstPort)}, nil
+}
@@ -0,0 +1,172 @@
+package godzilla
+
+import (
+	"fmt"
+	"html/template"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+// PanicError describes a panic recovered while handling a request
+type PanicError struct {
+	Value   interface{}       // value passed to panic
+	Stack   string            // stack of the panicking goroutine
+	Method  string            // request method
+	Path    string            // request path
+	Route   string            // matched route, e.g. "/users/:id"
+	Params  map[string]string // route params
+	Headers map[string]string // request headers
+	Locals  map[string]string // locals formatted with %v
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// RecoverConfig holds the recover middleware settings
+type RecoverConfig struct {
+	// Called with every recovered panic, e.g. to send it to an error tracker
+	Report func(ctx Context, err *PanicError) // default nil
+}
+
+// Recover returns a middleware recovering from panics of the next handlers.
+// The panic is logged with its stack and answered with a json error, or with
+// a debug page when Settings.Development is set.
+func Recover(config ...*RecoverConfig) handlerFunc {
+	cfg := &RecoverConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(ctx Context) {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				handlePanic(ctx, rcv, cfg.Report)
+			}
+		}()
+
+		ctx.Next()
+	}
+}
+
+// handlePanic logs and reports a recovered panic, then answers it with a json
+// error or the debug page in development
+func handlePanic(ctx Context, rcv interface{}, report func(ctx Context, err *PanicError)) {
+	err := logPanic(ctx, rcv)
+
+	if report != nil {
+		report(ctx, err)
+	}
+
+	fctx := ctx.Context()
+	fctx.Response.Reset()
+	fctx.SetStatusCode(StatusInternalServerError)
+
+	if settingsOf(ctx).Development {
+		fctx.SetContentType("text/html; charset=utf-8")
+		if err := debugPage.Execute(fctx, err); err != nil {
+			fctx.SetBodyString(err.Error())
+		}
+		return
+	}
+
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": fasthttp.StatusMessage(fasthttp.StatusInternalServerError),
+	})
+	fctx.SetContentType(MimeApplicationJSON)
+	fctx.SetBody(body)
+}
+
+// logPanic logs a recovered panic with its stack and request details
+func logPanic(ctx Context, rcv interface{}) *PanicError {
+	err := newPanicError(ctx, rcv)
+
+	requestLogger(ctx).Error("recovered from panic", "error", fmt.Sprint(rcv),
+		"method", err.Method, "path", err.Path, "route", err.Route, "stack", err.Stack)
+	return err
+}
+
+// settingsOf returns the settings of the server handling ctx
+func settingsOf(ctx Context) *Settings {
+	if c, ok := ctx.(*context); ok {
+		return c.settings()
+	}
+	return &Settings{}
+}
+
+func newPanicError(ctx Context, rcv interface{}) *PanicError {
+	fctx := ctx.Context()
+
+	err := &PanicError{
+		Value:   rcv,
+		Stack:   string(debug.Stack()),
+		Method:  string(fctx.Method()),
+		Path:    string(fctx.Path()),
+		Route:   ctx.Route(),
+		Params:  make(map[string]string),
+		Headers: make(map[string]string),
+		Locals:  make(map[string]string),
+	}
+
+	if c, ok := ctx.(*context); ok {
+		for key, value := range c.paramValues {
+			err.Params[key] = value
+		}
+	}
+
+	fctx.Request.Header.VisitAll(func(key, value []byte) {
+		err.Headers[string(key)] = string(value)
+	})
+
+	fctx.VisitUserValues(func(key []byte, value interface{}) {
+		err.Locals[string(key)] = fmt.Sprintf("%v", value)
+	})
+	return err
+}
+
+// sortedPairs returns the entries of m sorted by key, used by the debug page
+func sortedPairs(m map[string]string) [][2]string {
+	pairs := make([][2]string, 0, len(m))
+	for key, value := range m {
+		pairs = append(pairs, [2]string{key, value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+var debugPage = template.Must(template.New("panic").Funcs(template.FuncMap{
+	"pairs": sortedPairs,
+	"lines": func(s string) []string { return strings.Split(strings.TrimSpace(s), "\n") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>panic: {{printf "%v" .Value}}</title>
+<style>
+body { font-family: -apple-system, sans-serif; margin: 2em; color: #1f2937; }
+h1 { color: #dc2626; font-size: 1.4em; }
+pre { background: #f3f4f6; padding: 1em; overflow-x: auto; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td { border-bottom: 1px solid #e5e7eb; padding: .3em 1em .3em 0; vertical-align: top; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>panic: {{printf "%v" .Value}}</h1>
+<p><code>{{.Method}} {{.Path}}</code>{{if .Route}} matched <code>{{.Route}}</code>{{end}}</p>
+<h2>Stack</h2>
+<pre>{{range lines .Stack}}{{.}}
+{{end}}</pre>
+<h2>Params</h2>
+<table>{{range pairs .Params}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>{{else}}<tr><td>none</td></tr>{{end}}</table>
+<h2>Headers</h2>
+<table>{{range pairs .Headers}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>{{else}}<tr><td>none</td></tr>{{end}}</table>
+<h2>Locals</h2>
+<table>{{range pairs .Locals}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>{{else}}<tr><td>none</td></tr>{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,86 @@
+package godzilla
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var panicHandler = func(ctx Context) {
+	ctx.SetLocal("user", "gopher")
+	panic("database is gone")
+}
+
+// TestRecover tests the json error, the debug page and the report hook
+func TestRecover(t *testing.T) {
+	testCases := []struct {
+		development bool
+		contentType string
+		contains    []string
+	}{
+		{contentType: MimeApplicationJSON, contains: []string{`{"error":"Internal Server Error"}`}},
+		{development: true, contentType: "text/html; charset=utf-8", contains: []string{
+			"panic: database is gone", "/users/:id", "recover_test.go", "X-Debug", "id</td><td>42", "user</td><td>gopher",
+		}},
+	}
+
+	for _, tc := range testCases {
+		var reported *PanicError
+
+		gz := setupGodzilla(&Settings{Development: tc.development})
+		gz.Use(Recover(&RecoverConfig{
+			Report: func(ctx Context, err *PanicError) { reported = err },
+		}))
+		gz.Get("/users/:id", panicHandler)
+		startGodzilla(gz)
+
+		req, _ := http.NewRequest(MethodGet, "/users/42", nil)
+		req.Header.Set("X-Debug", "yes")
+
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("development %v: %s", tc.development, err.Error())
+		}
+
+		if response.StatusCode != StatusInternalServerError {
+			t.Fatalf("development %v: returned %d expected %d", tc.development, response.StatusCode, StatusInternalServerError)
+		}
+
+		if contentType := response.Header.Get("Content-Type"); contentType != tc.contentType {
+			t.Fatalf("development %v: returned content type %s expected %s", tc.development, contentType, tc.contentType)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		for _, s := range tc.contains {
+			if !strings.Contains(string(body), s) {
+				t.Fatalf("development %v: returned %s expected it to contain %s", tc.development, body, s)
+			}
+		}
+
+		if reported == nil || reported.Route != "/users/:id" || reported.Params["id"] != "42" {
+			t.Fatalf("development %v: reported %+v", tc.development, reported)
+		}
+	}
+}
+
+// TestAutoRecoverLogsStack tests that AutoRecover logs the panic with its stack
+func TestAutoRecoverLogsStack(t *testing.T) {
+	var out bytes.Buffer
+
+	gz := setupGodzilla(&Settings{AutoRecover: true, Logger: NewTextLogger(&out, LevelInfo)})
+	gz.Get("/users/:id", panicHandler)
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodGet, "/users/42", nil)
+	if _, err := makeRequest(req, gz); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{`error="database is gone"`, "route=/users/:id", "recover_test.go"} {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("logged %q expected it to contain %q", out.String(), s)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package godzilla
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// HeaderTraceparent is the W3C trace context header
+const HeaderTraceparent = "traceparent"
+
+// maxRequestIDLength bounds the incoming ids that are trusted
+const maxRequestIDLength = 128
+
+// RequestIDConfig holds the request id middleware settings
+type RequestIDConfig struct {
+	Header string // default "X-Request-ID"
+
+	// Generates the id of requests that carry none, see UUIDv4 and ULID
+	Generator func() string // default UUIDv4
+
+	// Do not reuse the trace id of an incoming traceparent header
+	IgnoreTraceparent bool // default false
+}
+
+// RequestID returns a middleware giving every request an id, it is read from
+// the request header, the trace id of traceparent or generated. The id is set
+// on the response, returned by ctx.RequestID and added to framework log lines.
+func RequestID(config ...*RequestIDConfig) handlerFunc {
+	cfg := &RequestIDConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	header := stringOr(cfg.Header, HeaderXRequestID)
+	generator := cfg.Generator
+	if generator == nil {
+		generator = UUIDv4
+	}
+
+	return func(ctx Context) {
+		id := ctx.Get(header)
+		if !validRequestID(id) {
+			id = ""
+			if !cfg.IgnoreTraceparent {
+				id = traceID(ctx.Get(HeaderTraceparent))
+			}
+		}
+
+		if id == "" {
+			id = generator()
+		} else {
+			// header values alias the request buffer
+			id = string([]byte(id))
+		}
+
+		ctx.Set(header, id)
+		ctx.SetLocal(localRequestID, id)
+		ctx.Next()
+
+		// error responses, e.g. of Recover or fasthttp, reset the headers
+		ctx.Set(header, id)
+	}
+}
+
+const localRequestID = "godzilla.requestid"
+
+// requestLogger returns the framework logger with the request id attached
+func requestLogger(ctx Context) LeveledLogger {
+	logger := settingsOf(ctx).logger()
+	if id := ctx.RequestID(); id != "" {
+		return logger.With("request_id", id)
+	}
+	return logger
+}
+
+// validRequestID accepts short ids made of url-safe characters, anything else
+// may be an attempt to inject into logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '=', c == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traceID returns the trace id of a traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func traceID(traceparent string) string {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+
+	// version 00 has exactly four fields, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+
+	for _, part := range parts[:3] {
+		if !isLowerHex(part) {
+			return ""
+		}
+	}
+
+	if parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	return parts[1]
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// UUIDv4 returns a random RFC 4122 uuid, e.g. "0b5a2ac6-7dd0-4b3c-9a0e-1f4d2c8e6b7a"
+func UUIDv4() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("requestid: " + err.Error())
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// crockford is the base32 alphabet of ulids
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a lexicographically sortable id made of a millisecond
+// timestamp and 80 random bits, e.g. "01HF8Z6Q9K3V7W2N5XJ4R8T6YB"
+func ULID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixNano()/int64(time.Millisecond))<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic("requestid: " + err.Error())
+	}
+
+	// 26 characters of 5 bits hold the 128 bits with two leading zero bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
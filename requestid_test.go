@@ -0,0 +1,96 @@
+package godzilla
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestRequestID tests reading, propagating and generating request ids
+func TestRequestID(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(RequestID())
+	gz.Get("/id", func(ctx Context) {
+		ctx.SendString(ctx.RequestID())
+	})
+	startGodzilla(gz)
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	testCases := []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{headers: map[string]string{HeaderXRequestID: "abc-123"}, expected: "abc-123"},
+		{headers: map[string]string{HeaderTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, expected: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{headers: map[string]string{HeaderXRequestID: "id\twith spaces", HeaderTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, expected: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{headers: map[string]string{HeaderTraceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"}},
+		{headers: map[string]string{HeaderXRequestID: strings.Repeat("a", 129)}},
+		{},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, "/id", nil)
+		for key, value := range tc.headers {
+			req.Header.Set(key, value)
+		}
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		if response.Header.Get(HeaderXRequestID) != string(body) {
+			t.Fatalf("%v: response header %q does not match %q", tc.headers, response.Header.Get(HeaderXRequestID), body)
+		}
+
+		if tc.expected != "" && string(body) != tc.expected {
+			t.Fatalf("%v: returned %q expected %q", tc.headers, body, tc.expected)
+		} else if tc.expected == "" && !uuid.Match(body) {
+			t.Fatalf("%v: generated %q is not a uuid", tc.headers, body)
+		}
+	}
+}
+
+// TestRequestIDLogs tests the request id is attached to framework log lines
+func TestRequestIDLogs(t *testing.T) {
+	var out bytes.Buffer
+	gz := setupGodzilla(&Settings{Logger: NewTextLogger(&out, LevelInfo)})
+	gz.Use(RequestID(&RequestIDConfig{Generator: ULID}))
+	gz.Use(Recover())
+	gz.Get("/panic", func(ctx Context) {
+		panic("boom")
+	})
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodGet, "/panic", nil)
+	response, err := makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := response.Header.Get(HeaderXRequestID)
+	if !regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`).MatchString(id) {
+		t.Fatalf("generated %q is not a ulid", id)
+	}
+	if !strings.Contains(out.String(), "request_id="+id) {
+		t.Fatalf("log %q does not contain request id %s", out.String(), id)
+	}
+}
+
+// TestULID tests ulids sort by creation time
+func TestULID(t *testing.T) {
+	first := ULID()
+	for i := 0; i < 3; i++ {
+		if ULID()[:10] < first[:10] {
+			t.Fatalf("ulid timestamp went backwards")
+		}
+	}
+
+	if ULID() == ULID() {
+		t.Fatalf("ulids are not random")
+	}
+}
@@ -1,7 +1,7 @@
 package godzilla
 
 import (
-	"log"
+	gocontext "context"
 	"strings"
 	"sync"
 
@@ -20,11 +20,22 @@ type router struct {
 	notFound handlersChain
 	settings *Settings
 	pool     sync.Pool
+
+	// global middlewares, they also run for OPTIONS requests answered by the router
+	middlewares handlersChain
+
+	// peers whose forwarding headers are trusted, see Settings.TrustedProxies
+	trustedProxies ipRanges
+
+	// parent of request contexts, cancelled on shutdown
+	baseCtx    gocontext.Context
+	cancelBase gocontext.CancelFunc
 }
 
 type matchResult struct {
 	handlers handlersChain
 	params   map[string]string
+	route    string
 }
 
 func (r *router) acquireCtx(fctx *fasthttp.RequestCtx) *context {
@@ -33,14 +44,35 @@ func (r *router) acquireCtx(fctx *fasthttp.RequestCtx) *context {
 	ctx.index = 0
 	ctx.paramValues = make(map[string]string)
 	ctx.requestCtx = fctx
+	ctx.router = r
 
 	return ctx
 }
 
 func (r *router) releaseCtx(ctx *context) {
+	// the server reads the next request from the connection once this returns
+	if ctx.unwatch != nil {
+		ctx.unwatch()
+	}
+	if ctx.cancel != nil {
+		ctx.cancel()
+	}
+
+	// a handler goroutine still uses the context, it must not be reused
+	if ctx.detached {
+		return
+	}
+
+	ctx.goctx = nil
+	ctx.cancel = nil
+	ctx.unwatch = nil
+	ctx.client = nil
+	ctx.clientOnce = sync.Once{}
 	ctx.handlers = nil
 	ctx.paramValues = nil
 	ctx.requestCtx = nil
+	ctx.route = ""
+	ctx.router = nil
 	r.pool.Put(ctx)
 }
 
@@ -109,18 +141,28 @@ func (r *router) allowed(reqMethod, path string, ctx *context) string {
 	return allow
 }
 
+// routingPath returns the path routes are matched against, and whether it was
+// lowercased because Settings.CaseInSensitive is set
+func routingPath(ctx Context) (string, bool) {
+	path := GetString(ctx.Context().URI().PathOriginal())
+	if c, ok := ctx.(*context); ok && c.settings().CaseInSensitive {
+		return strings.ToLower(path), true
+	}
+	return path, false
+}
+
 func (r *router) Handler(fctx *fasthttp.RequestCtx) {
 	context := r.acquireCtx(fctx)
 	defer r.releaseCtx(context)
 
 	if r.settings.AutoRecover {
-		defer func(fctx *fasthttp.RequestCtx) {
+		defer func() {
 			if rcv := recover(); rcv != nil {
-				log.Printf("recovered from error: %v", rcv)
+				logPanic(context, rcv)
 				fctx.Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError),
 					fasthttp.StatusInternalServerError)
 			}
-		}(fctx)
+		}()
 	}
 
 	path := GetString(fctx.URI().PathOriginal())
@@ -142,6 +184,7 @@ func (r *router) Handler(fctx *fasthttp.RequestCtx) {
 		if ok {
 			context.handlers = cacheResult.handlers
 			context.paramValues = cacheResult.params
+			context.route = cacheResult.route
 			r.mutex.RUnlock()
 			context.handlers[0](context)
 			return
@@ -164,6 +207,7 @@ func (r *router) Handler(fctx *fasthttp.RequestCtx) {
 				r.cache[cacheKey] = &matchResult{
 					handlers: handlers,
 					params:   context.paramValues,
+					route:    context.route,
 				}
 				r.cacheLen++
 				r.mutex.Unlock()
@@ -174,7 +218,15 @@ func (r *router) Handler(fctx *fasthttp.RequestCtx) {
 
 	if method == MethodOptions && r.settings.HandleOPTIONS {
 		if allow := r.allowed(method, path, context); len(allow) > 0 {
-			fctx.Response.Header.Set("Allow", allow)
+			// matching the other methods left their route and params behind
+			context.route = ""
+			context.paramValues = make(map[string]string)
+
+			// let global middlewares, e.g. CORS, answer preflight requests
+			context.handlers = append(r.middlewares[:len(r.middlewares):len(r.middlewares)], func(ctx Context) {
+				ctx.Set("Allow", allow)
+			})
+			context.handlers[0](context)
 			return
 		}
 	} else if r.settings.HandleMethodNotAllowed {
@@ -196,6 +248,13 @@ func (r *router) Handler(fctx *fasthttp.RequestCtx) {
 		fasthttp.StatusNotFound)
 }
 
+// shutdown cancels the contexts of running requests
+func (r *router) shutdown() {
+	if r.cancelBase != nil {
+		r.cancelBase()
+	}
+}
+
 func (r *router) SetNotFound(handlers handlersChain) {
 	r.notFound = append(r.notFound, handlers...)
 }
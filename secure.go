@@ -0,0 +1,189 @@
+package godzilla
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Security headers
+const (
+	HeaderStrictTransportSecurity   = "Strict-Transport-Security"
+	HeaderContentSecurityPolicy     = "Content-Security-Policy"
+	HeaderXFrameOptions             = "X-Frame-Options"
+	HeaderXContentTypeOptions       = "X-Content-Type-Options"
+	HeaderReferrerPolicy            = "Referrer-Policy"
+	HeaderPermissionsPolicy         = "Permissions-Policy"
+	HeaderCrossOriginOpenerPolicy   = "Cross-Origin-Opener-Policy"
+	HeaderCrossOriginEmbedderPolicy = "Cross-Origin-Embedder-Policy"
+	HeaderCrossOriginResourcePolicy = "Cross-Origin-Resource-Policy"
+)
+
+// SecureConfig holds the security headers middleware settings, empty fields
+// use the defaults and headers listed in Omit are not sent
+type SecureConfig struct {
+	HSTSMaxAge            time.Duration // default 365 days
+	HSTSExcludeSubdomains bool          // default false
+	HSTSPreload           bool          // default false
+
+	// Policy where {nonce} is replaced by a per-request nonce, see CSPNonce
+	ContentSecurityPolicy string // default "default-src 'self'; script-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+
+	XFrameOptions             string // default "DENY"
+	XContentTypeOptions       string // default "nosniff"
+	ReferrerPolicy            string // default "strict-origin-when-cross-origin"
+	PermissionsPolicy         string // default "camera=(), microphone=(), geolocation=(), payment=()"
+	CrossOriginOpenerPolicy   string // default "same-origin"
+	CrossOriginEmbedderPolicy string // default "" (not sent, "require-corp" breaks most third-party embeds)
+	CrossOriginResourcePolicy string // default "same-origin"
+
+	// Header names that are not sent
+	Omit []string // default nil
+
+	// Locals key of the csp nonce, e.g. for templates
+	ContextKey string // default "cspNonce"
+
+	// Overrides of specific routes keyed by route path, their non-empty fields
+	// replace the ones above and their Omit lists are added
+	Routes map[string]*SecureConfig // default nil
+}
+
+const localCSPNonce = "godzilla.secure.nonce"
+
+// secureHeaders are the precomputed headers of a route
+type secureHeaders struct {
+	headers [][2]string
+	nonce   bool // the csp contains {nonce}
+}
+
+// Secure returns a middleware setting security headers with secure defaults
+func Secure(config ...*SecureConfig) handlerFunc {
+	cfg := &SecureConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	contextKey := stringOr(cfg.ContextKey, "cspNonce")
+
+	base := cfg.headers()
+	routes := make(map[string]*secureHeaders, len(cfg.Routes))
+	for route, override := range cfg.Routes {
+		routes[route] = cfg.merge(override).headers()
+	}
+
+	return func(ctx Context) {
+		headers := base
+		if route, ok := routes[ctx.Route()]; ok {
+			headers = route
+		}
+
+		var nonce string
+		if headers.nonce {
+			nonce = newCSPNonce()
+			ctx.SetLocal(contextKey, nonce)
+			ctx.SetLocal(localCSPNonce, nonce)
+		}
+
+		for _, header := range headers.headers {
+			value := header[1]
+			if header[0] == HeaderContentSecurityPolicy && headers.nonce {
+				value = strings.ReplaceAll(value, "{nonce}", nonce)
+			}
+			ctx.Set(header[0], value)
+		}
+
+		ctx.Next()
+	}
+}
+
+// CSPNonce returns the nonce of the Content-Security-Policy of the request,
+// scripts and styles must carry it in their nonce attribute
+func CSPNonce(ctx Context) string {
+	nonce, _ := ctx.GetLocal(localCSPNonce).(string)
+	return nonce
+}
+
+func (cfg *SecureConfig) headers() *secureHeaders {
+	omit := make(map[string]bool, len(cfg.Omit))
+	for _, name := range cfg.Omit {
+		omit[strings.ToLower(name)] = true
+	}
+
+	hsts := "max-age=" + strconv.Itoa(int(durationOr(cfg.HSTSMaxAge, 365*24*time.Hour)/time.Second))
+	if !cfg.HSTSExcludeSubdomains {
+		hsts += "; includeSubDomains"
+	}
+	if cfg.HSTSPreload {
+		hsts += "; preload"
+	}
+
+	csp := stringOr(cfg.ContentSecurityPolicy, "default-src 'self'; script-src 'self' 'nonce-{nonce}'; "+
+		"object-src 'none'; base-uri 'self'; frame-ancestors 'none'")
+
+	h := &secureHeaders{}
+	for _, header := range [][2]string{
+		{HeaderStrictTransportSecurity, hsts},
+		{HeaderContentSecurityPolicy, csp},
+		{HeaderXFrameOptions, stringOr(cfg.XFrameOptions, "DENY")},
+		{HeaderXContentTypeOptions, stringOr(cfg.XContentTypeOptions, "nosniff")},
+		{HeaderReferrerPolicy, stringOr(cfg.ReferrerPolicy, "strict-origin-when-cross-origin")},
+		{HeaderPermissionsPolicy, stringOr(cfg.PermissionsPolicy, "camera=(), microphone=(), geolocation=(), payment=()")},
+		{HeaderCrossOriginOpenerPolicy, stringOr(cfg.CrossOriginOpenerPolicy, "same-origin")},
+		{HeaderCrossOriginEmbedderPolicy, cfg.CrossOriginEmbedderPolicy},
+		{HeaderCrossOriginResourcePolicy, stringOr(cfg.CrossOriginResourcePolicy, "same-origin")},
+	} {
+		if header[1] == "" || omit[strings.ToLower(header[0])] {
+			continue
+		}
+		if header[0] == HeaderContentSecurityPolicy {
+			h.nonce = strings.Contains(header[1], "{nonce}")
+		}
+		h.headers = append(h.headers, header)
+	}
+	return h
+}
+
+// merge returns cfg with the non-empty fields of override
+func (cfg *SecureConfig) merge(override *SecureConfig) *SecureConfig {
+	merged := *cfg
+	merged.Omit = append(append([]string(nil), cfg.Omit...), override.Omit...)
+
+	if override.HSTSMaxAge != 0 {
+		merged.HSTSMaxAge = override.HSTSMaxAge
+	}
+	merged.HSTSExcludeSubdomains = merged.HSTSExcludeSubdomains || override.HSTSExcludeSubdomains
+	merged.HSTSPreload = merged.HSTSPreload || override.HSTSPreload
+
+	for _, field := range []struct{ dst, src *string }{
+		{&merged.ContentSecurityPolicy, &override.ContentSecurityPolicy},
+		{&merged.XFrameOptions, &override.XFrameOptions},
+		{&merged.XContentTypeOptions, &override.XContentTypeOptions},
+		{&merged.ReferrerPolicy, &override.ReferrerPolicy},
+		{&merged.PermissionsPolicy, &override.PermissionsPolicy},
+		{&merged.CrossOriginOpenerPolicy, &override.CrossOriginOpenerPolicy},
+		{&merged.CrossOriginEmbedderPolicy, &override.CrossOriginEmbedderPolicy},
+		{&merged.CrossOriginResourcePolicy, &override.CrossOriginResourcePolicy},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	return &merged
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("secure: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
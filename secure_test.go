@@ -0,0 +1,80 @@
+package godzilla
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestSecure tests default headers, csp nonces and route overrides
+func TestSecure(t *testing.T) {
+	gz := setupGodzilla()
+	gz.Use(Secure(&SecureConfig{
+		HSTSPreload: true,
+		Routes: map[string]*SecureConfig{
+			"/embed": {XFrameOptions: "SAMEORIGIN", ContentSecurityPolicy: "frame-ancestors 'self'", Omit: []string{HeaderCrossOriginOpenerPolicy}},
+		},
+	}))
+	gz.Get("/page", func(ctx Context) {
+		ctx.SendString(CSPNonce(ctx))
+	})
+	gz.Get("/embed", pingHandler)
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodGet, "/page", nil)
+	response, err := makeRequest(req, gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(response.Body)
+	nonce := string(body)
+	if nonce == "" || !strings.Contains(response.Header.Get(HeaderContentSecurityPolicy), "'nonce-"+nonce+"'") {
+		t.Fatalf("returned nonce %q and policy %q", nonce, response.Header.Get(HeaderContentSecurityPolicy))
+	}
+
+	testCases := []struct {
+		path    string
+		headers map[string]string
+	}{
+		{path: "/page", headers: map[string]string{
+			HeaderStrictTransportSecurity:   "max-age=31536000; includeSubDomains; preload",
+			HeaderXFrameOptions:             "DENY",
+			HeaderXContentTypeOptions:       "nosniff",
+			HeaderReferrerPolicy:            "strict-origin-when-cross-origin",
+			HeaderCrossOriginOpenerPolicy:   "same-origin",
+			HeaderCrossOriginEmbedderPolicy: "",
+		}},
+		{path: "/embed", headers: map[string]string{
+			HeaderStrictTransportSecurity: "max-age=31536000; includeSubDomains; preload",
+			HeaderXFrameOptions:           "SAMEORIGIN",
+			HeaderContentSecurityPolicy:   "frame-ancestors 'self'",
+			HeaderCrossOriginOpenerPolicy: "",
+		}},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, tc.path, nil)
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.path, err.Error())
+		}
+
+		for key, value := range tc.headers {
+			if actual := response.Header.Get(key); actual != value {
+				t.Fatalf("%s: header %s returned %q expected %q", tc.path, key, actual, value)
+			}
+		}
+	}
+
+	// nonces are not reused
+	req, _ = http.NewRequest(MethodGet, "/page", nil)
+	response, _ = makeRequest(req, gz)
+	if response.Header.Get(HeaderContentSecurityPolicy) == "" {
+		t.Fatalf("returned no policy")
+	}
+	if strings.Contains(response.Header.Get(HeaderContentSecurityPolicy), nonce) {
+		t.Fatalf("nonce %s was reused", nonce)
+	}
+}
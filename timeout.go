@@ -0,0 +1,70 @@
+package godzilla
+
+import (
+	gocontext "context"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TimeoutConfig holds the timeout middleware settings
+type TimeoutConfig struct {
+	// Status of requests that exceeded the timeout, e.g. StatusGatewayTimeout
+	StatusCode int // default StatusServiceUnavailable
+
+	// Body of requests that exceeded the timeout
+	Message string // default the status message
+}
+
+// Timeout returns a middleware answering requests whose handlers run longer
+// than timeout. The handlers keep running in their goroutine until they
+// return, they should watch ctx.Ctx() which is cancelled at the deadline.
+// Their response is discarded and the request context is not reused.
+func Timeout(timeout time.Duration, config ...*TimeoutConfig) handlerFunc {
+	cfg := &TimeoutConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	statusCode := cfg.StatusCode
+	if statusCode == 0 {
+		statusCode = StatusServiceUnavailable
+	}
+	message := stringOr(cfg.Message, fasthttp.StatusMessage(statusCode))
+
+	return func(ctx Context) {
+		c, ok := ctx.(*context)
+		if !ok {
+			ctx.Next()
+			return
+		}
+
+		parent := c.Ctx()
+		deadline, cancel := gocontext.WithTimeout(parent, timeout)
+		c.goctx = deadline
+
+		done := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				done <- recover()
+			}()
+			ctx.Next()
+		}()
+
+		select {
+		case rcv := <-done:
+			c.goctx = parent
+			cancel()
+
+			// let recover middlewares registered before handle the panic
+			if rcv != nil {
+				panic(rcv)
+			}
+		case <-deadline.Done():
+			cancel()
+
+			c.detached = true
+			c.requestCtx.TimeoutErrorWithCode(message, statusCode)
+		}
+	}
+}
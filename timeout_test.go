@@ -0,0 +1,162 @@
+package godzilla
+
+import (
+	gocontext "context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestTimeout tests that slow handlers are answered and their context cancelled
+func TestTimeout(t *testing.T) {
+	cancelled := make(chan error, 1)
+
+	gz := setupGodzilla()
+	gz.Get("/slow", Timeout(20*time.Millisecond), func(ctx Context) {
+		<-ctx.Ctx().Done()
+		cancelled <- ctx.Ctx().Err()
+	})
+	gz.Get("/gateway", Timeout(20*time.Millisecond, &TimeoutConfig{StatusCode: StatusGatewayTimeout, Message: "upstream too slow"}), func(ctx Context) {
+		<-ctx.Ctx().Done()
+	})
+	gz.Get("/fast", Timeout(time.Second), pingHandler)
+	gz.Get("/panic", Recover(), Timeout(time.Second), func(ctx Context) {
+		panic("in handler goroutine")
+	})
+	startGodzilla(gz)
+
+	testCases := []struct {
+		path       string
+		statusCode int
+		body       string
+	}{
+		{path: "/slow", statusCode: StatusServiceUnavailable, body: "Service Unavailable"},
+		{path: "/gateway", statusCode: StatusGatewayTimeout, body: "upstream too slow"},
+		{path: "/fast", statusCode: StatusOK, body: "pong"},
+		{path: "/panic", statusCode: StatusInternalServerError, body: `{"error":"Internal Server Error"}`},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, tc.path, nil)
+		response, err := makeRequest(req, gz)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.path, err.Error())
+		}
+
+		if response.StatusCode != tc.statusCode {
+			t.Fatalf("%s: returned %d expected %d", tc.path, response.StatusCode, tc.statusCode)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		if string(body) != tc.body {
+			t.Fatalf("%s: returned %s expected %s", tc.path, body, tc.body)
+		}
+	}
+
+	if err := <-cancelled; err != gocontext.DeadlineExceeded {
+		t.Fatalf("handler context returned %v expected %v", err, gocontext.DeadlineExceeded)
+	}
+}
+
+// TestCtx tests that request contexts end with the request and the server
+func TestCtx(t *testing.T) {
+	var requestCtx gocontext.Context
+	stopped := make(chan error, 1)
+
+	gz := setupGodzilla()
+	gz.router.baseCtx, gz.router.cancelBase = gocontext.WithCancel(gocontext.Background())
+	gz.Get("/ctx", func(ctx Context) {
+		requestCtx = ctx.Ctx()
+	})
+	gz.Get("/wait", func(ctx Context) {
+		<-ctx.Ctx().Done()
+		stopped <- ctx.Ctx().Err()
+	})
+	startGodzilla(gz)
+
+	req, _ := http.NewRequest(MethodGet, "/ctx", nil)
+	if _, err := makeRequest(req, gz); err != nil {
+		t.Fatal(err)
+	}
+
+	if requestCtx == nil || requestCtx.Err() != gocontext.Canceled {
+		t.Fatalf("request context was not cancelled after the request")
+	}
+
+	go func() {
+		req, _ := http.NewRequest(MethodGet, "/wait", nil)
+		makeRequest(req, gz)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	gz.router.shutdown()
+
+	select {
+	case err := <-stopped:
+		if err != gocontext.Canceled {
+			t.Fatalf("request context returned %v expected %v", err, gocontext.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request context was not cancelled on shutdown")
+	}
+}
+
+// TestCtxClosedConn tests that request contexts end when the client closes the
+// connection and that watching it leaves keep-alive requests intact
+func TestCtxClosedConn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("closed connections are not noticed on windows")
+	}
+
+	stopped := make(chan error, 1)
+
+	gz := setupGodzilla()
+	gz.Get("/wait", func(ctx Context) {
+		select {
+		case <-ctx.Ctx().Done():
+			stopped <- ctx.Ctx().Err()
+		case <-time.After(time.Second):
+			stopped <- nil
+		}
+	})
+	gz.Get("/ctx", func(ctx Context) {
+		ctx.Ctx()
+		ctx.SendString("pong")
+	})
+	startGodzilla(gz)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go gz.httpServer.Serve(ln)
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("GET /wait HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	time.Sleep(20 * time.Millisecond)
+	conn.Close()
+
+	if err := <-stopped; err != gocontext.Canceled {
+		t.Fatalf("request context returned %v expected %v", err, gocontext.Canceled)
+	}
+
+	client := &http.Client{}
+	for i := 0; i < 3; i++ {
+		response, err := client.Get("http://" + ln.Addr().String() + "/ctx")
+		if err != nil {
+			t.Fatalf("request %d: %s", i, err.Error())
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if string(body) != "pong" {
+			t.Fatalf("request %d: returned %s expected pong", i, body)
+		}
+	}
+}
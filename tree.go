@@ -15,6 +15,7 @@ const (
 
 type node struct {
 	path     string
+	route    string // full path of the route registered on this node
 	param    *node
 	children map[string]*node
 	nType    nodeType
@@ -39,6 +40,7 @@ func (n *node) addRoute(path string, handlers handlersChain) {
 			copy(routeHandlers, handlers)
 
 			currentNode.handlers = routeHandlers
+			currentNode.route = originalPath
 			break
 		}
 
@@ -137,6 +139,9 @@ func (n *node) matchRoute(path string, ctx *context) handlersChain {
 		pathLen = len(path)
 
 		if pathLen == 0 || currentNode.nType == catchAll {
+			if currentNode.handlers != nil {
+				ctx.route = currentNode.route
+			}
 			return currentNode.handlers
 		}
 		segmentDelimiter := strings.Index(path, "/")
@@ -0,0 +1,230 @@
+package godzilla
+
+import (
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Forwarding headers
+const (
+	HeaderForwarded       = "Forwarded"
+	HeaderXForwardedFor   = "X-Forwarded-For"
+	HeaderXForwardedProto = "X-Forwarded-Proto"
+	HeaderXForwardedHost  = "X-Forwarded-Host"
+	HeaderXRealIP         = "X-Real-IP"
+)
+
+// ipRanges is a list of networks, single addresses are stored as /32 or /128
+type ipRanges []*net.IPNet
+
+// parseIPRanges parses CIDRs and plain addresses
+func parseIPRanges(values []string) (ipRanges, error) {
+	ranges := make(ipRanges, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: value}
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, network)
+	}
+	return ranges, nil
+}
+
+// contains reports whether ip is in one of the ranges
+func (r ipRanges) contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range r {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarded is what trusted proxies reported about the client of a request
+type forwarded struct {
+	ips    []net.IP // client first, up to the peer
+	scheme string
+	host   string
+}
+
+// resolveForwarded walks the forwarding chain from the peer towards the client
+// and stops at the first address that is not a trusted proxy
+func resolveForwarded(fctx *fasthttp.RequestCtx, trusted ipRanges) *forwarded {
+	peer := fctx.RemoteIP()
+	result := &forwarded{ips: []net.IP{peer}}
+	if !trusted.contains(peer) {
+		return result
+	}
+
+	var chain []net.IP
+	var elements []map[string]string
+
+	if value := headerValues(fctx, HeaderForwarded); value != "" {
+		elements = parseForwarded(value)
+		for _, element := range elements {
+			chain = append(chain, parseHostIP(element["for"]))
+		}
+	} else if value := headerValues(fctx, HeaderXForwardedFor); value != "" {
+		for _, part := range strings.Split(value, ",") {
+			chain = append(chain, parseHostIP(part))
+		}
+	} else if ip := parseHostIP(GetString(fctx.Request.Header.Peek(HeaderXRealIP))); ip != nil {
+		chain = []net.IP{ip}
+	}
+
+	// the client is the last hop reported by a trusted proxy
+	client := len(chain)
+	for client > 0 {
+		ip := chain[client-1]
+		if ip == nil {
+			break
+		}
+		client--
+		if !trusted.contains(ip) {
+			break
+		}
+	}
+
+	if client < len(chain) {
+		result.ips = append(chain[client:len(chain):len(chain)], peer)
+	}
+
+	if elements != nil {
+		// the element of the client hop was written by the first trusted proxy
+		index := client
+		if index >= len(elements) {
+			index = len(elements) - 1
+		}
+		result.scheme = strings.ToLower(elements[index]["proto"])
+		result.host = elements[index]["host"]
+	}
+
+	if result.scheme == "" {
+		result.scheme = strings.ToLower(firstValue(GetString(fctx.Request.Header.Peek(HeaderXForwardedProto))))
+	}
+	if result.host == "" {
+		result.host = firstValue(GetString(fctx.Request.Header.Peek(HeaderXForwardedHost)))
+	}
+	return result
+}
+
+// headerValues joins the values of every header named name
+func headerValues(fctx *fasthttp.RequestCtx, name string) string {
+	var values []string
+	fctx.Request.Header.VisitAll(func(key, value []byte) {
+		if strings.EqualFold(GetString(key), name) {
+			values = append(values, GetString(value))
+		}
+	})
+	return strings.Join(values, ",")
+}
+
+func firstValue(list string) string {
+	value, _, _ := cut(list, ",")
+	return strings.TrimSpace(value)
+}
+
+// parseForwarded parses the elements of an RFC 7239 Forwarded header, e.g.
+// `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`
+func parseForwarded(value string) []map[string]string {
+	var elements []map[string]string
+	element := make(map[string]string)
+
+	var key strings.Builder
+	var val strings.Builder
+	inValue, quoted, escaped := false, false, false
+
+	flush := func() {
+		if k := strings.ToLower(strings.TrimSpace(key.String())); k != "" {
+			element[k] = strings.TrimSpace(val.String())
+		}
+		key.Reset()
+		val.Reset()
+		inValue = false
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case escaped:
+			val.WriteByte(c)
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			val.WriteByte(c)
+		case c == ';':
+			flush()
+		case c == ',':
+			flush()
+			elements = append(elements, element)
+			element = make(map[string]string)
+		case c == '=' && !inValue:
+			inValue = true
+		case inValue:
+			val.WriteByte(c)
+		default:
+			key.WriteByte(c)
+		}
+	}
+	flush()
+	return append(elements, element)
+}
+
+// parseHostIP parses an address with an optional port, ipv6 addresses with a
+// port are enclosed in brackets. Obfuscated and unknown nodes return nil.
+func parseHostIP(value string) net.IP {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if ip := net.ParseIP(value); ip != nil {
+		return ip
+	}
+
+	if strings.HasPrefix(value, "[") {
+		end := strings.IndexByte(value, ']')
+		if end < 0 {
+			return nil
+		}
+		return net.ParseIP(value[1:end])
+	}
+
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// forwarded returns the client details of the request, forwarding headers are
+// only read when the peer is one of Settings.TrustedProxies and only once per
+// request, handlers of a Timeout goroutine may ask concurrently
+func (ctx *context) forwarded() *forwarded {
+	ctx.clientOnce.Do(func() {
+		var trusted ipRanges
+		if ctx.router != nil {
+			trusted = ctx.router.trustedProxies
+		}
+		ctx.client = resolveForwarded(ctx.requestCtx, trusted)
+	})
+	return ctx.client
+}
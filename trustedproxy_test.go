@@ -0,0 +1,144 @@
+package godzilla
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestTrustedProxies tests client details are only read from forwarding
+// headers of trusted peers
+func TestTrustedProxies(t *testing.T) {
+	handler := func(ctx Context) {
+		ctx.SendString(ctx.IP() + " " + strings.Join(ctx.IPs(), ",") + " " + ctx.Scheme() + " " + ctx.Host())
+	}
+
+	trusted := setupGodzilla(&Settings{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8"}})
+	trusted.Get("/", handler)
+	startGodzilla(trusted)
+
+	untrusted := setupGodzilla(&Settings{TrustedProxies: []string{"10.0.0.0/8"}})
+	untrusted.Get("/", handler)
+	startGodzilla(untrusted)
+
+	testCases := []struct {
+		gz       *godzilla
+		headers  map[string]string
+		expected string
+	}{
+		{gz: trusted, expected: "127.0.0.1 127.0.0.1 http example.com"},
+		{gz: trusted, headers: map[string]string{HeaderXForwardedFor: "203.0.113.7", HeaderXForwardedProto: "https", HeaderXForwardedHost: "api.example.com"}, expected: "203.0.113.7 203.0.113.7,127.0.0.1 https api.example.com"},
+		{gz: trusted, headers: map[string]string{HeaderXForwardedFor: "198.51.100.1, 203.0.113.7, 10.0.0.2"}, expected: "203.0.113.7 203.0.113.7,10.0.0.2,127.0.0.1 http example.com"},
+		{gz: trusted, headers: map[string]string{HeaderXForwardedFor: "10.0.0.3, 10.0.0.2"}, expected: "10.0.0.3 10.0.0.3,10.0.0.2,127.0.0.1 http example.com"},
+		{gz: trusted, headers: map[string]string{HeaderXForwardedFor: "203.0.113.7, garbage"}, expected: "127.0.0.1 127.0.0.1 http example.com"},
+		{gz: trusted, headers: map[string]string{HeaderXRealIP: "203.0.113.9"}, expected: "203.0.113.9 203.0.113.9,127.0.0.1 http example.com"},
+		{gz: trusted, headers: map[string]string{HeaderForwarded: `for=198.51.100.1;proto=http, for="[2001:db8::17]:4711";proto=https;host=www.example.com, for=10.0.0.2;proto=http`}, expected: "2001:db8::17 2001:db8::17,10.0.0.2,127.0.0.1 https www.example.com"},
+		{gz: untrusted, headers: map[string]string{HeaderXForwardedFor: "203.0.113.7", HeaderXForwardedProto: "https", HeaderForwarded: "for=203.0.113.7;host=evil.com"}, expected: "127.0.0.1 127.0.0.1 http example.com"},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(MethodGet, "http://example.com/", nil)
+		for key, value := range tc.headers {
+			req.Header.Set(key, value)
+		}
+		response, err := makeRequest(req, tc.gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		if string(body) != tc.expected {
+			t.Fatalf("%v: returned %q expected %q", tc.headers, body, tc.expected)
+		}
+	}
+}
+
+// TestProxyProtocol tests reading PROXY protocol headers
+func TestProxyProtocol(t *testing.T) {
+	v2 := func(command, family byte, addrs []byte) []byte {
+		header := append([]byte(nil), proxyV2Signature...)
+		header = append(header, 0x20|command, family, 0, 0)
+		binary.BigEndian.PutUint16(header[14:], uint16(len(addrs)))
+		return append(header, addrs...)
+	}
+	tcp4 := []byte{192, 0, 2, 1, 10, 0, 0, 1, 0xdc, 0x04, 0x01, 0xbb, 0x01, 0x02} // trailing tlv bytes
+
+	testCases := []struct {
+		header []byte
+		remote string
+		local  string
+		err    bool
+	}{
+		{header: []byte("PROXY TCP4 192.0.2.1 10.0.0.1 56324 443\r\n"), remote: "192.0.2.1:56324", local: "10.0.0.1:443"},
+		{header: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"), remote: "[2001:db8::1]:56324", local: "[2001:db8::2]:443"},
+		{header: []byte("PROXY UNKNOWN\r\n")},
+		{header: []byte("PROXY TCP4 192.0.2.1\r\n"), err: true},
+		{header: []byte("PROXY TCP4 192.0.2.1 10.0.0.1 56324 443\n"), err: true},
+		{header: v2(0x1, 0x11, tcp4), remote: "192.0.2.1:56324", local: "10.0.0.1:443"},
+		{header: v2(0x0, 0x00, nil)},
+		{header: v2(0x1, 0x11, tcp4[:4]), err: true},
+		{},
+	}
+
+	for _, tc := range testCases {
+		r := bufio.NewReader(bytes.NewReader(append(tc.header, "GET / HTTP/1.1\r\n"...)))
+		remote, local, err := readProxyHeader(r)
+		if (err != nil) != tc.err {
+			t.Fatalf("%q: returned error %v", tc.header, err)
+		}
+		if tc.err {
+			continue
+		}
+
+		if remote == nil && tc.remote != "" || remote != nil && remote.String() != tc.remote {
+			t.Fatalf("%q: returned remote %v expected %q", tc.header, remote, tc.remote)
+		}
+		if local == nil && tc.local != "" || local != nil && local.String() != tc.local {
+			t.Fatalf("%q: returned local %v expected %q", tc.header, local, tc.local)
+		}
+
+		// the request follows the header
+		if line, _ := r.ReadString('\n'); line != "GET / HTTP/1.1\r\n" {
+			t.Fatalf("%q: left %q", tc.header, line)
+		}
+	}
+
+	// the proxied address is the peer of the connection
+	gz := setupGodzilla(&Settings{TrustedProxies: []string{"127.0.0.1"}, ProxyProtocol: true})
+	gz.Get("/", func(ctx Context) {
+		ctx.SendString(ctx.IP())
+	})
+	startGodzilla(gz)
+
+	c := &fakeConn{}
+	c.r.WriteString("PROXY TCP4 192.0.2.1 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+	conn, _ := gz.wrapListener(&fakeListener{conn: c}).Accept()
+	if err := gz.httpServer.ServeConn(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.ReadResponse(bufio.NewReader(&c.w), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	if string(body) != "192.0.2.1" {
+		t.Fatalf("returned %q expected 192.0.2.1", body)
+	}
+}
+
+// fakeListener accepts a single connection
+type fakeListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (ln *fakeListener) Accept() (net.Conn, error) {
+	return ln.conn, nil
+}